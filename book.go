@@ -0,0 +1,209 @@
+// Package ghess is a chess engine. This file concerns building
+// opening books from PGN games, as Polyglot books or as JSON
+// opening dictionaries for DictionaryAttack.
+package ghess
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+)
+
+// BookOptions choose the games and moves of a BookBuilder.
+type BookOptions struct {
+	Plies    int     // plies of each game added, 0 for all
+	MinGames int     // games a move must be played in to be kept
+	MinScore float64 // score a move must make, 0 to 1 for its player
+}
+
+// BookBuilder adds up how often moves are played in each
+// position, and how they score, over many games.
+type BookBuilder struct {
+	opts      BookOptions
+	positions map[uint64]*bookPosition // by Polyglot key
+	games     int
+}
+
+// bookPosition is a position seen while building.
+type bookPosition struct {
+	key   string // of the opening dictionary
+	moves map[uint16]*bookStats
+}
+
+// bookStats are the games of a move.
+type bookStats struct {
+	games  int
+	points float64 // for the player of the move
+}
+
+// NewBookBuilder returns an empty BookBuilder.
+func NewBookBuilder(opts BookOptions) *BookBuilder {
+	return &BookBuilder{
+		opts:      opts,
+		positions: make(map[uint64]*bookPosition),
+	}
+}
+
+// Games returns the number of games added in full.
+func (bb *BookBuilder) Games() int {
+	return bb.games
+}
+
+// AddGame adds the first plies of a game. Games without a
+// result, or with a move that can't be played, are left out.
+func (bb *BookBuilder) AddGame(g PgnGame) error {
+	var white float64
+	switch g.Result {
+	case "1-0":
+		white = 1
+	case "0-1":
+		white = 0
+	case "1/2-1/2":
+		white = 0.5
+	default:
+		return errors.New("Game has no result")
+	}
+	// Only add the moves once the whole game replays
+	type played struct {
+		dictKey  string
+		polyglot uint64
+		move     uint16
+		points   float64
+	}
+	var moves []played
+	_, err := g.Replay(bb.opts.Plies, func(b *Board, orig, dest int) {
+		points := white
+		if b.toMove == "b" {
+			points = 1 - white
+		}
+		moves = append(moves, played{
+			dictKey:  b.dictionaryKey(),
+			polyglot: b.PolyglotKey(),
+			move:     b.polyglotEncode(orig, dest),
+			points:   points,
+		})
+	})
+	if err != nil {
+		return err
+	}
+	bb.games++
+	for _, m := range moves {
+		pos, ok := bb.positions[m.polyglot]
+		if !ok {
+			pos = &bookPosition{key: m.dictKey, moves: make(map[uint16]*bookStats)}
+			bb.positions[m.polyglot] = pos
+		}
+		stats, ok := pos.moves[m.move]
+		if !ok {
+			stats = &bookStats{}
+			pos.moves[m.move] = stats
+		}
+		stats.games++
+		stats.points += m.points
+	}
+	return nil
+}
+
+// keep returns true if the move passes the filters.
+func (bb *BookBuilder) keep(stats *bookStats) bool {
+	return stats.games >= bb.opts.MinGames &&
+		stats.points/float64(stats.games) >= bb.opts.MinScore
+}
+
+// weight is two for a win and one for a draw, as
+// Polyglot books usually weigh moves.
+func (stats *bookStats) weight() uint16 {
+	if w := 2 * stats.points; w < 0xFFFF {
+		return uint16(w)
+	}
+	return 0xFFFF
+}
+
+// Book returns the moves kept as a Polyglot book.
+func (bb *BookBuilder) Book() *Book {
+	var entries []polyglotEntry
+	for key, pos := range bb.positions {
+		for move, stats := range pos.moves {
+			if bb.keep(stats) {
+				entries = append(entries, polyglotEntry{
+					Key:    key,
+					Move:   move,
+					Weight: stats.weight(),
+				})
+			}
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Key != entries[j].Key {
+			return entries[i].Key < entries[j].Key
+		}
+		return entries[i].Weight > entries[j].Weight
+	})
+	return &Book{entries: entries}
+}
+
+// Dictionary returns the heaviest move kept in each position,
+// as the opening dictionary of DictionaryAttack.
+func (bb *BookBuilder) Dictionary() map[string][2]int {
+	dictionary := make(map[string][2]int)
+	for _, pos := range bb.positions {
+		var best *bookStats
+		var bestMove uint16
+		for move, stats := range pos.moves {
+			if !bb.keep(stats) {
+				continue
+			}
+			if best == nil || stats.weight() > best.weight() ||
+				stats.weight() == best.weight() && move < bestMove {
+				best, bestMove = stats, move
+			}
+		}
+		if best != nil {
+			orig, dest, _ := polyglotMove(bestMove)
+			dictionary[pos.key] = [2]int{orig, dest}
+		}
+	}
+	return dictionary
+}
+
+// WriteDictionary writes an opening dictionary as JSON, the
+// pieces and player to move of each position with the Board
+// coordinates of its move.
+func WriteDictionary(w io.Writer, dictionary map[string][2]int) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "\t")
+	return enc.Encode(dictionary)
+}
+
+// ReadDictionary reads an opening dictionary written by
+// WriteDictionary, to be used by DictionaryAttack in place
+// of the built in one. It must not be called while searching.
+func ReadDictionary(r io.Reader) error {
+	var dictionary map[string][2]int
+	if err := json.NewDecoder(r).Decode(&dictionary); err != nil {
+		return err
+	}
+	for key, move := range dictionary {
+		for _, idx := range move {
+			if idx < 11 || idx > 88 || idx%10 == 0 || idx%10 == 9 {
+				return fmt.Errorf("Dictionary move of %q is off the board", key)
+			}
+		}
+	}
+	dict = dictionary
+	return nil
+}
+
+// LoadDictionary reads the opening dictionary
+// at path, see ReadDictionary.
+func LoadDictionary(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return ReadDictionary(f)
+}
@@ -0,0 +1,100 @@
+package ghess
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+const bookPgn = `[Result "1-0"]
+1. e4 e5 1-0
+
+[Result "0-1"]
+1. e4 c5 0-1
+
+[Result "1/2-1/2"]
+1. d4 d5 1/2-1/2
+`
+
+func testBuilder(t *testing.T, opts BookOptions) *BookBuilder {
+	games, err := ReadPgn(strings.NewReader(bookPgn))
+	if err != nil {
+		t.Fatal(err)
+	}
+	bb := NewBookBuilder(opts)
+	for _, g := range games {
+		if err := bb.AddGame(g); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := bb.AddGame(PgnGame{Moves: []string{"e4"}, Result: "*"}); err == nil {
+		t.Error("Game without a result should be an error")
+	}
+	if err := bb.AddGame(PgnGame{Moves: []string{"e4", "e4"}, Result: "1-0"}); err == nil {
+		t.Error("Game with an illegal move should be an error")
+	}
+	if bb.Games() != len(games) {
+		t.Error("Only games replayed in full count", bb.Games())
+	}
+	return bb
+}
+
+func TestBuildBook(t *testing.T) {
+	game := NewBoard()
+	book := testBuilder(t, BookOptions{Plies: 2}).Book()
+	moves := book.Moves(&game)
+	if len(moves) != 2 {
+		t.Fatal("Should be e4 and d4", moves)
+	}
+	if moves[0] != (BookMove{24, 44, 2}) || moves[1] != (BookMove{25, 45, 1}) {
+		t.Error("e4 scored 1 in 2 games, d4 half in 1", moves)
+	}
+	standard := false
+	for _, entry := range book.entries {
+		standard = standard || entry.Key == 0x463b96181691fc9c
+	}
+	if !standard {
+		t.Error("The start should have the standard Polyglot key")
+	}
+	var buf bytes.Buffer
+	if err := book.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	read, err := ReadBook(&buf)
+	if err != nil || read.Len() != 5 {
+		t.Error("Book should read back 5 entries", err)
+	}
+
+	book = testBuilder(t, BookOptions{MinGames: 2}).Book()
+	if book.Len() != 1 {
+		t.Error("Only e4 is played twice", book.Len())
+	}
+	book = testBuilder(t, BookOptions{MinScore: 0.6}).Book()
+	_ = game.ParseMove("e4")
+	if m, err := book.Pick(&game, false); err != nil || m.Dest != 56 {
+		t.Error("Only c5 scores above 0.6", m, err)
+	}
+}
+
+func TestBookDictionary(t *testing.T) {
+	saved := dict
+	defer func() { dict = saved }()
+
+	dictionary := testBuilder(t, BookOptions{}).Dictionary()
+	var buf bytes.Buffer
+	if err := WriteDictionary(&buf, dictionary); err != nil {
+		t.Fatal(err)
+	}
+	if err := ReadDictionary(&buf); err != nil {
+		t.Fatal(err)
+	}
+	game := NewBoard()
+	_ = game.ParseMove("d4")
+	state, err := DictionaryAttack(GetState(&game))
+	if err != nil || state.Init != [2]int{75, 55} {
+		t.Error("Should answer d4 with d5", state.Init, err)
+	}
+	if err := ReadDictionary(strings.NewReader(`{"x w": [0, 99]}`)); err == nil {
+		t.Error("Move off the board should be an error")
+	}
+}
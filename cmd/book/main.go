@@ -0,0 +1,69 @@
+// Command book builds an opening book from PGN files, as a
+// Polyglot .bin book for SearchOptions.Book, or as a JSON
+// opening dictionary for LoadDictionary.
+//
+//	book -plies 16 -min-games 3 -out book.bin games.pgn more.pgn
+//	book -format json -out openings.json games.pgn
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/polypmer/ghess"
+)
+
+func main() {
+	plies := flag.Int("plies", 20, "plies of each game to add, 0 for all")
+	minGames := flag.Int("min-games", 1, "games a move must be played in")
+	minScore := flag.Float64("min-score", 0, "score a move must make for its player, 0 to 1")
+	format := flag.String("format", "bin", "bin for a Polyglot book, json for a dictionary")
+	out := flag.String("out", "book.bin", "file to write the book to")
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		log.Fatal("book: give PGN files to read")
+	}
+	if *format != "bin" && *format != "json" {
+		log.Fatal("book: -format is bin or json")
+	}
+	builder := ghess.NewBookBuilder(ghess.BookOptions{
+		Plies:    *plies,
+		MinGames: *minGames,
+		MinScore: *minScore,
+	})
+	for _, path := range flag.Args() {
+		games, err := ghess.LoadPgnFile(path)
+		if err != nil {
+			log.Fatal(err)
+		}
+		for i, game := range games {
+			if err := builder.AddGame(game); err != nil {
+				log.Printf("%s game %d: %v", path, i+1, err)
+			}
+		}
+	}
+
+	if *format == "bin" {
+		book := builder.Book()
+		if err := book.Save(*out); err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("%d games, %d book entries written to %s\n", builder.Games(), book.Len(), *out)
+		return
+	}
+	dictionary := builder.Dictionary()
+	f, err := os.Create(*out)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := ghess.WriteDictionary(f, dictionary); err != nil {
+		log.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("%d games, %d positions written to %s\n", builder.Games(), len(dictionary), *out)
+}
@@ -0,0 +1,56 @@
+// Command server serves ghess games over HTTP in JSON, see
+// the server package for the routes, and games between players
+// over websockets at /rooms/{name}, see the multiplayer package.
+//
+//	server -addr :8080 -max-depth 6 -max-time 5s -book book.bin
+//	server -clock 5m -increment 3s
+//	curl -X POST localhost:8080/games
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/polypmer/ghess"
+	"github.com/polypmer/ghess/multiplayer"
+	"github.com/polypmer/ghess/server"
+)
+
+func main() {
+	addr := flag.String("addr", ":8080", "address to listen on")
+	depth := flag.Int("depth", 4, "depth of the engine when none is asked")
+	maxDepth := flag.Int("max-depth", 8, "deepest search allowed")
+	maxTime := flag.Duration("max-time", 10*time.Second, "longest search allowed")
+	threads := flag.Int("threads", 1, "threads of each search")
+	bookFile := flag.String("book", "", "Polyglot book for the engine")
+	clock := flag.Duration("clock", 0, "clock of each player in rooms, 0 for none")
+	increment := flag.Duration("increment", 0, "added to a player's clock after each move")
+	flag.Parse()
+
+	search := ghess.DefaultOptions()
+	search.Threads = *threads
+	if *bookFile != "" {
+		book, err := ghess.OpenBook(*bookFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		search.Book = book
+	}
+	s := server.New(server.Options{
+		Search:   search,
+		Depth:    *depth,
+		MaxDepth: *maxDepth,
+		MaxTime:  *maxTime,
+	})
+	mux := http.NewServeMux()
+	mux.Handle("/games", s)
+	mux.Handle("/games/", s)
+	mux.Handle("/rooms/", multiplayer.NewHub(multiplayer.Options{
+		Clock:     *clock,
+		Increment: *increment,
+	}))
+	log.Printf("server: listening on %s", *addr)
+	log.Fatal(http.ListenAndServe(*addr, mux))
+}
@@ -0,0 +1,65 @@
+// Command tune tunes the ghess evaluation weights to a file
+// of quiet positions labelled with their game results, and
+// writes the new weights to a JSON file for LoadParams.
+//
+//	tune -positions quiet.epd -out params.json -weights material,passedPawn
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/polypmer/ghess"
+)
+
+func main() {
+	positionsPath := flag.String("positions", "", "file of positions and results, one per line")
+	paramsPath := flag.String("params", "", "weights to start from, the defaults if empty")
+	out := flag.String("out", "params.json", "file to write the tuned weights to")
+	iterations := flag.Int("iterations", 0, "passes over the weights, 0 until none improve")
+	step := flag.Int("step", 1, "how far a weight is moved")
+	k := flag.Float64("k", 0, "sigmoid scaling, 0 to fit it to the positions")
+	weights := flag.String("weights", "", "comma separated weight names to tune, all if empty")
+	flag.Parse()
+
+	if *positionsPath == "" {
+		log.Fatal("tune: -positions is required")
+	}
+	positions, err := ghess.LoadTunePositions(*positionsPath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	ep := ghess.DefaultParams()
+	if *paramsPath != "" {
+		if ep, err = ghess.LoadParams(*paramsPath); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if *k == 0 {
+		*k = ghess.FitK(ep, positions)
+	}
+	fmt.Printf("%d positions, K %.3f, error %.6f\n",
+		len(positions), *k, ghess.TuneError(ep, positions, *k))
+
+	opts := ghess.TuneOptions{
+		K:          *k,
+		Step:       *step,
+		Iterations: *iterations,
+		Log: func(iteration int, mse float64) {
+			fmt.Printf("Iteration %d, error %.6f\n", iteration, mse)
+		},
+	}
+	if *weights != "" {
+		opts.Weights = strings.Split(*weights, ",")
+	}
+	tuned, _, err := ghess.Tune(ep, positions, opts)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := tuned.Save(*out); err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println("Weights written to", *out)
+}
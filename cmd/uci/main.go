@@ -0,0 +1,296 @@
+// Command uci runs ghess as a UCI engine over stdin and
+// stdout, for chess GUIs such as Arena, CuteChess and Scid.
+//
+// It understands uci, isready, ucinewgame, setoption,
+// position startpos/fen with moves, go with depth, movetime,
+// wtime, btime, winc, binc, movestogo and infinite, stop
+// and quit.
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/polypmer/ghess"
+)
+
+const (
+	name     = "ghess"
+	author   = "polypmer"
+	maxDepth = 64 // for go without a depth
+)
+
+// engine is the state between commands.
+type engine struct {
+	out   sync.Mutex // one line at a time
+	board ghess.Board
+	opts  ghess.SearchOptions
+
+	stop     chan struct{} // closed to stop the search
+	stopOnce *sync.Once
+	done     chan struct{} // closed when the search has answered
+}
+
+func main() {
+	e := &engine{board: ghess.NewBoard(), opts: ghess.DefaultOptions()}
+	scanner := bufio.NewScanner(os.Stdin)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		switch fields[0] {
+		case "uci":
+			e.send("id name " + name)
+			e.send("id author " + author)
+			e.send("option name Threads type spin default 1 min 1 max 64")
+			e.send("option name OwnBook type check default true")
+			e.send("option name BookFile type string default <empty>")
+			e.send("option name BookRandom type check default false")
+			e.send("option name EvalFile type string default <empty>")
+			e.send("option name NetworkFile type string default <empty>")
+			e.send("option name SyzygyPath type string default <empty>")
+			e.send("uciok")
+		case "isready":
+			e.send("readyok")
+		case "ucinewgame":
+			e.stopSearch()
+			e.board = ghess.NewBoard()
+		case "setoption":
+			e.stopSearch()
+			e.setOption(fields[1:])
+		case "position":
+			e.stopSearch()
+			e.position(fields[1:])
+		case "go":
+			e.stopSearch()
+			e.goSearch(fields[1:])
+		case "stop":
+			e.stopSearch()
+		case "quit":
+			e.stopSearch()
+			return
+		}
+	}
+}
+
+// send writes a line to the GUI.
+func (e *engine) send(line string) {
+	e.out.Lock()
+	defer e.out.Unlock()
+	fmt.Println(line)
+}
+
+// setOption handles "setoption name <id> [value <x>]".
+func (e *engine) setOption(args []string) {
+	var id, value []string
+	target := &id
+	for _, arg := range args {
+		switch arg {
+		case "name":
+			target = &id
+		case "value":
+			target = &value
+		default:
+			*target = append(*target, arg)
+		}
+	}
+	val := strings.Join(value, " ")
+	if val == "<empty>" {
+		val = ""
+	}
+	var err error
+	switch strings.ToLower(strings.Join(id, " ")) {
+	case "threads":
+		e.opts.Threads, err = strconv.Atoi(val)
+	case "ownbook":
+		e.opts.NoDictionary = val != "true"
+	case "bookfile":
+		e.opts.Book = nil
+		if val != "" {
+			e.opts.Book, err = ghess.OpenBook(val)
+		}
+	case "bookrandom":
+		e.opts.BookRandom = val == "true"
+	case "evalfile":
+		e.opts.Params = nil
+		if val != "" {
+			e.opts.Params, err = ghess.LoadParams(val)
+		}
+	case "networkfile":
+		e.opts.Evaluator = nil
+		if val != "" {
+			var net *ghess.Network
+			if net, err = ghess.LoadNetwork(val); err == nil {
+				e.opts.Evaluator = net
+			}
+		}
+	case "syzygypath":
+		e.opts.Tablebase = nil
+		if val != "" {
+			var tb *ghess.Syzygy
+			if tb, err = ghess.OpenSyzygy(val); err == nil {
+				e.opts.Tablebase = tb
+			}
+		}
+	default:
+		err = fmt.Errorf("no such option %q", strings.Join(id, " "))
+	}
+	if err != nil {
+		e.send("info string " + err.Error())
+	}
+}
+
+// position handles "position [startpos | fen <fen>] moves ...".
+func (e *engine) position(args []string) {
+	board := ghess.NewBoard()
+	if len(args) > 0 && args[0] == "fen" {
+		var fen []string
+		for args = args[1:]; len(args) > 0 && args[0] != "moves"; args = args[1:] {
+			fen = append(fen, args[0])
+		}
+		if err := board.LoadFen(strings.Join(fen, " ")); err != nil {
+			e.send("info string " + err.Error())
+			return
+		}
+	} else if len(args) > 0 && args[0] == "startpos" {
+		args = args[1:]
+	}
+	if len(args) > 0 && args[0] == "moves" {
+		for _, move := range args[1:] {
+			orig, dest, err := board.ParseUCI(move)
+			if err == nil {
+				err = board.Move(orig, dest)
+			}
+			if err != nil {
+				e.send("info string " + move + ": " + err.Error())
+				return
+			}
+		}
+	}
+	e.board = board
+}
+
+// goSearch handles "go", searching in the background until
+// the depth, the time or a stop.
+func (e *engine) goSearch(args []string) {
+	depth := maxDepth
+	var movetime, wtime, btime, winc, binc time.Duration
+	var movesToGo int
+	infinite := false
+	for i := 0; i < len(args); i++ {
+		var n int
+		if i+1 < len(args) {
+			n, _ = strconv.Atoi(args[i+1])
+		}
+		ms := time.Duration(n) * time.Millisecond
+		switch args[i] {
+		case "depth":
+			depth, i = n, i+1
+		case "movetime":
+			movetime, i = ms, i+1
+		case "wtime":
+			wtime, i = ms, i+1
+		case "btime":
+			btime, i = ms, i+1
+		case "winc":
+			winc, i = ms, i+1
+		case "binc":
+			binc, i = ms, i+1
+		case "movestogo":
+			movesToGo, i = n, i+1
+		case "infinite":
+			infinite = true
+		}
+	}
+	white := strings.Fields(e.board.Position())[1] == "w"
+	limit := movetime
+	if limit == 0 && !infinite {
+		if white && wtime > 0 {
+			limit = budget(wtime, winc, movesToGo)
+		} else if !white && btime > 0 {
+			limit = budget(btime, binc, movesToGo)
+		}
+	}
+
+	stop, done, once := make(chan struct{}), make(chan struct{}), &sync.Once{}
+	e.stop, e.done, e.stopOnce = stop, done, once
+	if limit > 0 {
+		time.AfterFunc(limit, func() { once.Do(func() { close(stop) }) })
+	}
+	board := e.board
+	opts := e.opts
+	opts.Stop = stop
+	opts.Info = func(info ghess.SearchInfo) {
+		e.send("info " + infoLine(&board, info, white))
+	}
+	state := ghess.GetState(&board)
+	state.SetOptions(opts)
+
+	go func() {
+		defer close(done)
+		best, err := ghess.IterativeDeepening(depth, state)
+		if infinite {
+			<-stop // only answer once told to stop
+		}
+		move := "0000"
+		if err == nil && best.Init != [2]int{} {
+			move = board.UCIMove(best.Init[0], best.Init[1])
+		} else if origs, dests := board.SearchValid(); len(origs) > 0 {
+			move = board.UCIMove(origs[0], dests[0])
+		}
+		e.send("bestmove " + move)
+	}()
+}
+
+// stopSearch stops a search, if one is running, and
+// waits for it to answer.
+func (e *engine) stopSearch() {
+	if e.done == nil {
+		return
+	}
+	e.stopOnce.Do(func() { close(e.stop) })
+	<-e.done
+	e.done = nil
+}
+
+// budget is the time for a move with the time left on the
+// clock: a share of it, plus most of the increment.
+func budget(left, inc time.Duration, movesToGo int) time.Duration {
+	if movesToGo <= 0 {
+		movesToGo = 30
+	}
+	limit := left/time.Duration(movesToGo) + inc*3/4
+	if limit > left/2 {
+		limit = left / 2
+	}
+	if limit < 10*time.Millisecond {
+		limit = 10 * time.Millisecond
+	}
+	return limit
+}
+
+// infoLine formats the info as UCI does, scores being
+// from the point of view of the player to move.
+func infoLine(b *ghess.Board, info ghess.SearchInfo, white bool) string {
+	score, mate := info.Score, info.Mate
+	if !white {
+		score, mate = -score, -mate
+	}
+	scoreText := fmt.Sprintf("cp %d", score)
+	if mate != 0 {
+		scoreText = fmt.Sprintf("mate %d", mate)
+	}
+	line := fmt.Sprintf("depth %d seldepth %d score %s nodes %d nps %d hashfull %d time %d",
+		info.Depth, info.SelDepth, scoreText, info.Nodes, info.NPS, info.HashFull,
+		info.Time.Nanoseconds()/1e6)
+	if pv := b.UCILine(info.PV); len(pv) > 0 {
+		line += " pv " + strings.Join(pv, " ")
+	}
+	return line
+}
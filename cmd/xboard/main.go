@@ -0,0 +1,355 @@
+// Command xboard runs ghess as a CECP (XBoard/WinBoard
+// protocol version 2) engine over stdin and stdout.
+//
+// It understands xboard, protover, new, force, go, usermove,
+// time, otim, level, st, sd, undo, remove, result, setboard,
+// ping, post, nopost, ? and quit. Moves are coordinates such
+// as e2e4 and e7e8q, though SAN is accepted from the GUI too.
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/polypmer/ghess"
+)
+
+const maxDepth = 64 // without sd
+
+// engine is the state between commands, owned by
+// the main loop.
+type engine struct {
+	board    ghess.Board
+	startFen string   // of setboard, empty for the start
+	moves    []string // played since, for undo
+	side     string   // w or b, the side the engine plays
+	force    bool     // play neither side
+	post     bool     // print thinking
+
+	depth          int           // sd, 0 for none
+	moveTime       time.Duration // st, 0 for none
+	clock, inc     time.Duration // time and level
+	movesPerPeriod int           // level, 0 for all
+
+	thinking *search
+	results  chan result
+
+	out   io.Writer
+	outMu sync.Mutex // the search posts its thinking too
+}
+
+// search is a search in the background.
+type search struct {
+	stop chan struct{}
+	once sync.Once
+}
+
+// halt closes stop, if the timer, ? or a cancel
+// haven't already.
+func (s *search) halt() {
+	s.once.Do(func() { close(s.stop) })
+}
+
+// result is the move a search found.
+type result struct {
+	search     *search
+	orig, dest int
+	ok         bool
+}
+
+func main() {
+	newEngine(os.Stdout).run(os.Stdin)
+}
+
+// newEngine returns an engine writing to out.
+func newEngine(out io.Writer) *engine {
+	return &engine{
+		board:   ghess.NewBoard(),
+		side:    "b",
+		results: make(chan result, 16),
+		out:     out,
+	}
+}
+
+// run reads commands from in until quit, or the end of in.
+func (e *engine) run(in io.Reader) {
+	lines := make(chan string)
+	go func() {
+		scanner := bufio.NewScanner(in)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+		close(lines)
+	}()
+	for {
+		select {
+		case line, ok := <-lines:
+			if !ok || !e.command(strings.Fields(line)) {
+				return
+			}
+		case res := <-e.results:
+			e.finish(res)
+		}
+	}
+}
+
+// send writes a line to the GUI.
+func (e *engine) send(format string, args ...interface{}) {
+	e.outMu.Lock()
+	defer e.outMu.Unlock()
+	fmt.Fprintf(e.out, format+"\n", args...)
+}
+
+// command handles a line from the GUI, returning false on quit.
+func (e *engine) command(fields []string) bool {
+	if len(fields) == 0 {
+		return true
+	}
+	arg := func(i int) int {
+		if i < len(fields) {
+			n, _ := strconv.Atoi(fields[i])
+			return n
+		}
+		return 0
+	}
+	switch fields[0] {
+	case "xboard", "accepted", "rejected", "random", "hard", "easy",
+		"computer", "name", "rating", "draw", "ics":
+	case "protover":
+		e.send(`feature myname="ghess" usermove=1 setboard=1 ping=1 san=0 ` +
+			`colors=0 sigint=0 sigterm=0 reuse=1 analyze=0 done=1`)
+	case "new":
+		e.cancel()
+		e.board, e.startFen, e.moves = ghess.NewBoard(), "", nil
+		e.side, e.force = "b", false
+		e.depth, e.moveTime = 0, 0
+	case "force", "result":
+		e.cancel()
+		e.force = true
+	case "go":
+		e.cancel()
+		e.force = false
+		e.side = e.toMove()
+		e.think()
+	case "?":
+		if e.thinking != nil {
+			e.thinking.halt() // answer with the best so far
+		}
+	case "usermove":
+		if len(fields) < 2 {
+			e.send("Error (no move): usermove")
+			break
+		}
+		e.cancel()
+		if err := e.play(fields[1]); err != nil {
+			e.send("Illegal move (%v): %s", err, fields[1])
+			break
+		}
+		if !e.gameOver() && !e.force && e.toMove() == e.side {
+			e.think()
+		}
+	case "time":
+		e.clock = time.Duration(arg(1)) * 10 * time.Millisecond
+	case "otim":
+	case "level":
+		e.movesPerPeriod = arg(1)
+		e.inc = time.Duration(arg(3)) * time.Second
+		e.moveTime = 0
+	case "st":
+		e.moveTime = time.Duration(arg(1)) * time.Second
+	case "sd":
+		e.depth = arg(1)
+	case "undo", "remove":
+		e.cancel()
+		n := 1
+		if fields[0] == "remove" {
+			n = 2
+		}
+		if n > len(e.moves) {
+			n = len(e.moves)
+		}
+		e.replay(e.moves[:len(e.moves)-n])
+	case "setboard":
+		e.cancel()
+		fen := strings.Join(fields[1:], " ")
+		board := ghess.NewBoard()
+		if err := board.LoadFen(fen); err != nil {
+			e.send("tellusererror Illegal position")
+			break
+		}
+		e.board, e.startFen, e.moves = board, fen, nil
+	case "ping":
+		e.send("pong %d", arg(1))
+	case "post":
+		e.post = true
+	case "nopost":
+		e.post = false
+	case "quit":
+		e.cancel()
+		return false
+	default:
+		e.send("Error (unknown command): %s", fields[0])
+	}
+	return true
+}
+
+// toMove returns w or b, the player to move.
+func (e *engine) toMove() string {
+	return strings.Fields(e.board.Position())[1]
+}
+
+// play plays a move in coordinates, or in SAN.
+func (e *engine) play(move string) error {
+	orig, dest, err := e.board.ParseUCI(move)
+	if err != nil {
+		// Take it as SAN, and find what it was
+		if err := e.board.ParseMove(move); err != nil {
+			return err
+		}
+		orig, dest = e.board.History[0], e.board.History[1]
+		e.replay(e.moves)
+	}
+	coords := e.board.UCIMove(orig, dest)
+	if err := e.board.Move(orig, dest); err != nil {
+		return err
+	}
+	e.moves = append(e.moves, coords)
+	return nil
+}
+
+// replay sets up the board again with the moves.
+func (e *engine) replay(moves []string) {
+	e.board = ghess.NewBoard()
+	if e.startFen != "" {
+		_ = e.board.LoadFen(e.startFen)
+	}
+	e.moves = nil
+	for _, move := range moves {
+		if err := e.play(move); err != nil {
+			return
+		}
+	}
+}
+
+// gameOver sends the result if the game has ended.
+func (e *engine) gameOver() bool {
+	switch {
+	case e.board.Checkmate && e.board.Score == "1-0":
+		e.send("1-0 {White mates}")
+	case e.board.Checkmate && e.board.Score == "0-1":
+		e.send("0-1 {Black mates}")
+	case e.board.Draw:
+		e.send("1/2-1/2 {Draw}")
+	default:
+		if origs, _ := e.board.SearchValid(); len(origs) == 0 {
+			e.send("1/2-1/2 {Stalemate}")
+			return true
+		}
+		return false
+	}
+	return true
+}
+
+// think starts searching for the engine's move.
+func (e *engine) think() {
+	s := &search{stop: make(chan struct{})}
+	e.thinking = s
+	if limit := e.limit(); limit > 0 {
+		time.AfterFunc(limit, s.halt)
+	}
+	depth := maxDepth
+	if e.depth > 0 {
+		depth = e.depth
+	}
+	board := e.board
+	opts := ghess.DefaultOptions()
+	opts.Stop = s.stop
+	if e.post {
+		start := time.Now()
+		white := e.toMove() == "w"
+		opts.Info = func(info ghess.SearchInfo) {
+			score, mate := info.Score, info.Mate
+			if !white {
+				score, mate = -score, -mate
+			}
+			// Mates are 100000 and the moves to mate, as XBoard shows them
+			if mate > 0 {
+				score = 100000 + mate
+			} else if mate < 0 {
+				score = -100000 + mate
+			}
+			e.send("%d %d %d %d %s", info.Depth, score, time.Since(start)/(10*time.Millisecond),
+				info.Nodes, strings.Join(board.UCILine(info.PV), " "))
+		}
+	}
+	state := ghess.GetState(&board)
+	state.SetOptions(opts)
+	go func() {
+		best, err := ghess.IterativeDeepening(depth, state)
+		res := result{search: s, orig: best.Init[0], dest: best.Init[1]}
+		res.ok = err == nil && best.Init != [2]int{}
+		if !res.ok {
+			if origs, dests := board.SearchValid(); len(origs) > 0 {
+				res.orig, res.dest, res.ok = origs[0], dests[0], true
+			}
+		}
+		e.results <- res
+	}()
+}
+
+// limit is the time for the engine's move.
+func (e *engine) limit() time.Duration {
+	if e.moveTime > 0 {
+		return e.moveTime
+	}
+	if e.clock <= 0 {
+		return 0
+	}
+	movesToGo := 30
+	if e.movesPerPeriod > 0 {
+		played := (len(e.moves) + 1) / 2
+		movesToGo = e.movesPerPeriod - played%e.movesPerPeriod
+	}
+	limit := e.clock/time.Duration(movesToGo) + e.inc*3/4
+	if limit > e.clock/2 {
+		limit = e.clock / 2
+	}
+	if limit < 10*time.Millisecond {
+		limit = 10 * time.Millisecond
+	}
+	return limit
+}
+
+// cancel stops the search, if any, without playing its move.
+func (e *engine) cancel() {
+	if e.thinking == nil {
+		return
+	}
+	e.thinking.halt()
+	e.thinking = nil
+}
+
+// finish plays the move of the current search.
+func (e *engine) finish(res result) {
+	if res.search != e.thinking {
+		return // cancelled
+	}
+	e.thinking = nil
+	if !res.ok {
+		e.gameOver()
+		return
+	}
+	move := e.board.UCIMove(res.orig, res.dest)
+	if err := e.play(move); err != nil {
+		e.send("Error (engine move %s): %v", move, err)
+		return
+	}
+	e.send("move %s", move)
+	e.gameOver()
+}
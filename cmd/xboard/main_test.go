@@ -0,0 +1,137 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+// gui drives an engine as XBoard would.
+type gui struct {
+	t     *testing.T
+	in    *io.PipeWriter
+	lines chan string
+	done  chan struct{}
+}
+
+// start runs an engine over pipes.
+func start(t *testing.T) *gui {
+	inR, inW := io.Pipe()
+	outR, outW := io.Pipe()
+	g := &gui{t: t, in: inW, lines: make(chan string, 1024), done: make(chan struct{})}
+	go func() {
+		newEngine(outW).run(inR)
+		close(g.done)
+		outW.Close()
+	}()
+	go func() {
+		scanner := bufio.NewScanner(outR)
+		for scanner.Scan() {
+			g.lines <- scanner.Text()
+		}
+		close(g.lines)
+	}()
+	return g
+}
+
+// send sends commands to the engine.
+func (g *gui) send(commands ...string) {
+	g.t.Helper()
+	for _, command := range commands {
+		if _, err := io.WriteString(g.in, command+"\n"); err != nil {
+			g.t.Fatal(err)
+		}
+	}
+}
+
+// expect reads lines until one starting with prefix,
+// skipping the thinking.
+func (g *gui) expect(prefix string) string {
+	g.t.Helper()
+	timeout := time.After(10 * time.Second)
+	for {
+		select {
+		case line, ok := <-g.lines:
+			if !ok {
+				g.t.Fatal("Engine quit waiting for", prefix)
+			}
+			if strings.HasPrefix(line, prefix) {
+				return line
+			}
+		case <-timeout:
+			g.t.Fatal("Timed out waiting for", prefix)
+		}
+	}
+}
+
+// quit ends the engine.
+func (g *gui) quit() {
+	g.t.Helper()
+	g.send("quit")
+	select {
+	case <-g.done:
+	case <-time.After(10 * time.Second):
+		g.t.Fatal("Engine didn't quit")
+	}
+}
+
+func TestHandshake(t *testing.T) {
+	g := start(t)
+	g.send("xboard", "protover 2")
+	if line := g.expect("feature"); !strings.Contains(line, "usermove=1") ||
+		!strings.HasSuffix(line, "done=1") {
+		t.Error("Unexpected features", line)
+	}
+	g.send("ping 7")
+	g.expect("pong 7")
+	g.send("nonsense")
+	g.expect("Error (unknown command): nonsense")
+	g.quit()
+}
+
+func TestUserMove(t *testing.T) {
+	g := start(t)
+	g.send("new", "sd 2", "usermove e2e5")
+	g.expect("Illegal move")
+	g.send("usermove e2e4")
+	if move := g.expect("move "); len(move) < len("move e7e5") {
+		t.Error("Unexpected engine move", move)
+	}
+	// SAN is taken too, and force stops the engine replying
+	g.send("force", "usermove Nf3", "ping 1")
+	if line := g.expect(""); line != "pong 1" {
+		t.Error("Engine moved in force mode", line)
+	}
+	g.quit()
+}
+
+func TestSetboard(t *testing.T) {
+	g := start(t)
+	// The halfmove clock has two digits
+	g.send("new", "force", "setboard 6k1/5ppp/8/8/8/8/5PPP/3R2K1 w - - 12 30",
+		"sd 2", "post", "go")
+	if line := g.expect("1 "); !strings.Contains(line, " 100001 ") {
+		t.Error("Should post mate in 1", line)
+	}
+	if move := g.expect("move "); move != "move d1d8" {
+		t.Error("Should mate with Rd8", move)
+	}
+	g.expect("1-0 {White mates}")
+	g.send("setboard nonsense")
+	g.expect("tellusererror Illegal position")
+	g.quit()
+}
+
+func TestMoveNow(t *testing.T) {
+	g := start(t)
+	// ? answers at once, however often it's sent, and
+	// while the clock stops the search too
+	g.send("new", "time 1", "usermove d2d4", "?", "?")
+	g.expect("move ")
+	g.send("?", "st 60", "usermove c2c4", "?", "?", "new", "?")
+	g.send("force", "usermove e2e4", "go", "?")
+	g.expect("move ")
+	g.quit()
+}
@@ -0,0 +1,146 @@
+// Package ghess is a chess engine. This file concerns naming
+// openings by their ECO code, from the table in eco.tsv.
+//
+// The table is the lichess chess-openings one, which is in the
+// public domain: some 3400 lines over the codes A00 to E99. Each
+// line is a code, a name and the moves, tab separated. Openings
+// are matched by position rather than by move order, so
+// transpositions are named too.
+package ghess
+
+import (
+	_ "embed" // for the ECO table
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+//go:embed eco.tsv
+var ecoData string
+
+// Opening is a named opening of the ECO table.
+type Opening struct {
+	ECO   string // such as C60
+	Name  string // such as Ruy Lopez
+	Moves string // the SAN moves of the table line
+}
+
+// ecoEntry is an Opening and the plies of its line.
+type ecoEntry struct {
+	Opening
+	plies int
+}
+
+var (
+	ecoOnce      sync.Once
+	ecoPositions map[string]ecoEntry // by openingKey
+	ecoPattern   = regexp.MustCompile(`^[A-E]\d\d$`)
+)
+
+// openingKey is the pieces, player to move and castling
+// of the position, which match whatever the move order.
+func (b *Board) openingKey() string {
+	fields := strings.Fields(b.Position())
+	return strings.Join(fields[:3], " ")
+}
+
+// readECO reads the ECO table, keeping the first opening
+// of the table which reaches a position.
+func readECO(data string) (map[string]ecoEntry, error) {
+	positions := make(map[string]ecoEntry)
+	for n, line := range strings.Split(data, "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		fields := strings.Split(line, "\t")
+		if len(fields) != 3 || !ecoPattern.MatchString(fields[0]) {
+			return nil, fmt.Errorf("ECO line %d should be a code, name and moves", n+1)
+		}
+		moves, _ := pgnMoves(fields[2])
+		b, err := PgnGame{Moves: moves}.Replay(0, nil)
+		if err != nil {
+			return nil, fmt.Errorf("ECO line %d: %v", n+1, err)
+		}
+		key := b.openingKey()
+		if _, ok := positions[key]; !ok {
+			positions[key] = ecoEntry{Opening{fields[0], fields[1], fields[2]}, len(moves)}
+		}
+	}
+	return positions, nil
+}
+
+// ecoTable returns the ECO table, read on first use.
+func ecoTable() map[string]ecoEntry {
+	ecoOnce.Do(func() {
+		var err error
+		if ecoPositions, err = readECO(ecoData); err != nil {
+			panic(err)
+		}
+	})
+	return ecoPositions
+}
+
+// Opening names the opening of the game, by the longest line of
+// the ECO table reaching one of its positions. It returns false
+// if none does.
+func (g PgnGame) Opening() (Opening, bool) {
+	table := ecoTable()
+	var best ecoEntry
+	found := false
+	look := func(b *Board) {
+		if entry, ok := table[b.openingKey()]; ok && (!found || entry.plies > best.plies) {
+			best, found = entry, true
+		}
+	}
+	// Openings are named from the moves that could be played
+	b, _ := g.Replay(0, func(b *Board, orig, dest int) {
+		look(b)
+	})
+	look(b)
+	return best.Opening, found
+}
+
+// SetOpening names the opening of the game in its ECO and
+// Opening tags, and returns false if it has no name.
+func (g *PgnGame) SetOpening() bool {
+	opening, ok := g.Opening()
+	if ok {
+		if g.Tags == nil {
+			g.Tags = make(map[string]string)
+		}
+		g.Tags["ECO"], g.Tags["Opening"] = opening.ECO, opening.Name
+	}
+	return ok
+}
+
+// Opening names the opening of the game played on the board
+// with ParseMove, or of the position if it wasn't played from
+// the start.
+func (b *Board) Opening() (Opening, bool) {
+	moves, _ := pgnMoves(b.pgn)
+	game := PgnGame{Moves: moves}
+	if end, err := game.Replay(0, nil); err == nil && end.openingKey() == b.openingKey() {
+		return game.Opening()
+	}
+	entry, ok := ecoTable()[b.openingKey()]
+	return entry.Opening, ok
+}
+
+// SetOpening writes ECO and Opening tags into the PGN
+// headers, and returns false if the opening has no name.
+func (b *Board) SetOpening() bool {
+	opening, ok := b.Opening()
+	if !ok {
+		return false
+	}
+	var headers string
+	for _, line := range strings.SplitAfter(b.headers, "\n") {
+		if line != "" && !strings.HasPrefix(line, "[ECO ") && !strings.HasPrefix(line, "[Opening ") {
+			headers += line
+		}
+	}
+	b.headers = headers + "[ECO \"" + opening.ECO + "\"]\n" +
+		"[Opening \"" + opening.Name + "\"]\n"
+	return true
+}
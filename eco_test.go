@@ -0,0 +1,70 @@
+package ghess
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestECOTable(t *testing.T) {
+	positions, err := readECO(ecoData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The codes A00 to E99, but for a few no line is filed under
+	codes := make(map[string]bool)
+	for _, entry := range positions {
+		codes[entry.ECO] = true
+	}
+	if len(positions) < 3000 || len(codes) < 490 {
+		t.Error("ECO table too short", len(positions), len(codes))
+	}
+	if _, err := readECO("C60\tRuy Lopez\te4 e5 Nf3 Nc6 Bb6\n"); err == nil {
+		t.Error("Illegal move should be an error")
+	}
+	if _, err := readECO("Ruy Lopez\te4\n"); err == nil {
+		t.Error("Missing code should be an error")
+	}
+}
+
+func TestGameOpening(t *testing.T) {
+	games, _ := ReadPgn(strings.NewReader(testPgn))
+	if !games[0].SetOpening() {
+		t.Fatal("Should name the opening")
+	}
+	if games[0].Tags["ECO"] != "C78" || games[0].Tags["Opening"] != "Ruy Lopez: Morphy Defense" {
+		t.Error("Should be the Ruy Lopez", games[0].Tags)
+	}
+	// Reti move order into the Queen's Pawn Game
+	opening, ok := PgnGame{Moves: []string{"Nf3", "d5", "d4", "Nf6", "Bf4", "e6"}}.Opening()
+	if !ok || opening.Name != "Queen's Pawn Game: London System" {
+		t.Error("Should find the transposition", opening)
+	}
+	if _, ok := (PgnGame{}).Opening(); ok {
+		t.Error("The starting position isn't an opening")
+	}
+}
+
+func TestBoardOpening(t *testing.T) {
+	game := NewBoard()
+	game.SetHeaders("A", "B")
+	for _, move := range []string{"e4", "c5", "Nf3", "d6", "d4", "cxd4", "Nxd4", "Nf6", "Nc3", "a6", "Be2"} {
+		if err := game.ParseMove(move); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if opening, _ := game.Opening(); opening.ECO != "B92" {
+		t.Error("Should be the Najdorf with Be2", opening)
+	}
+	game.SetOpening()
+	game.SetOpening()
+	if headers := game.PgnString(); strings.Count(headers, "[ECO \"B92\"]") != 1 ||
+		!strings.Contains(headers, "[Opening \"Sicilian Defense: Najdorf Variation, Opocensky Variation\"]") {
+		t.Error("Should write the ECO tags once", headers)
+	}
+	// Loaded positions are named by position
+	_ = game.LoadFen(`rnbqkbnr/pp1ppppp/8/2p5/4P3/8/PPPP1PPP/RNBQKBNR w KQkq - 0 2`)
+	game.pgn = ""
+	if opening, ok := game.Opening(); !ok || opening.ECO != "B20" {
+		t.Error("Should be the Sicilian", opening)
+	}
+}
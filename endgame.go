@@ -0,0 +1,333 @@
+// Package ghess is a chess engine. This file concerns the
+// specialised evaluation of basic endings, which the general
+// evaluation doesn't know how to win, or how to draw.
+package ghess
+
+import (
+	"strings"
+)
+
+// endgameFunc scores a position from the strong side's point
+// of view, given the normal evaluation from the same side.
+// Evaluators replace eval, scale factors shrink it towards 0.
+type endgameFunc func(b *Board, strongWhite bool, eval int) int
+
+// endgame is an evaluator registered for a material signature.
+type endgame struct {
+	name        string
+	strongWhite bool
+	score       endgameFunc
+}
+
+// endgameRule is an evaluator for endings which can't be
+// named by one signature, such as opposite coloured bishops.
+type endgameRule struct {
+	name  string
+	match func(counts *[128]int, strongWhite bool) bool
+	score endgameFunc
+}
+
+// knownWin is added to the score of endings which are won,
+// so the search never trades down from them.
+const knownWin = 5000
+
+// scaleNormal is a scale factor that leaves the score alone.
+const scaleNormal = 64
+
+var (
+	// endgames by material signature, see materialSignature
+	endgames = make(map[string]endgame)
+
+	// endgameRules are tried in order if no signature matches
+	endgameRules = []endgameRule{
+		{"KXK", matchKXK, evaluateKXK},
+		{"Wrong rook pawn", matchWrongRookPawn, scaleWrongRookPawn},
+		{"Opposite bishops", matchOppositeBishops, scaleOppositeBishops},
+		{"No pawns", matchNoPawns, scaleNoPawns},
+	}
+)
+
+func init() {
+	registerEndgame("KBNvK", evaluateKBNK)
+	registerEndgame("KPvK", evaluateKPK)
+	registerEndgame("KNNvK", scaleDraw)
+	registerEndgame("KNvK", scaleDraw)
+	registerEndgame("KBvK", scaleDraw)
+	registerEndgame("KvK", scaleDraw)
+}
+
+// registerEndgame registers an evaluator for a signature with
+// White as the strong side, and for the same with colours swapped.
+func registerEndgame(signature string, score endgameFunc) {
+	endgames[signature] = endgame{signature, true, score}
+	sides := strings.Split(signature, "v")
+	swapped := sides[1] + "v" + sides[0]
+	if _, ok := endgames[swapped]; !ok {
+		endgames[swapped] = endgame{signature, false, score}
+	}
+}
+
+// pieceCounts counts the pieces on the board by their byte.
+func (b *Board) pieceCounts() [128]int {
+	var counts [128]int
+	for idx := 11; idx < 89; idx++ {
+		if val := b.board[idx]; val != '.' && val != ' ' {
+			counts[val]++
+		}
+	}
+	return counts
+}
+
+// materialSignature names the material on the board, the White
+// pieces then the Black, such as KRPvKR.
+func materialSignature(counts *[128]int) string {
+	var sig []byte
+	for _, side := range [2]string{"KQRBNP", "kqrbnp"} {
+		if len(sig) > 0 {
+			sig = append(sig, 'v')
+		}
+		for i := 0; i < len(side); i++ {
+			for n := 0; n < counts[side[i]]; n++ {
+				sig = append(sig, ByteToUpper[side[i]])
+			}
+		}
+	}
+	return string(sig)
+}
+
+// pieceValue is the default material of a piece.
+func pieceValue(piece byte) int {
+	return defaultParams.material[piece]
+}
+
+// nonPawnMaterial returns the material of the pieces
+// of one side other than pawns and the King.
+func nonPawnMaterial(counts *[128]int, isWhite bool) int {
+	pieces := "NBRQ"
+	if !isWhite {
+		pieces = "nbrq"
+	}
+	var npm int
+	for i := 0; i < len(pieces); i++ {
+		npm += counts[pieces[i]] * pieceValue(pieces[i])
+	}
+	return npm
+}
+
+// sidePiece returns the byte of a piece for a side.
+func sidePiece(piece byte, isWhite bool) byte {
+	if isWhite {
+		return ByteToUpper[piece]
+	}
+	return ByteToLower[piece]
+}
+
+// endgameEval applies the evaluator matching the board, if
+// any, to the evaluation from White's point of view. It returns
+// the name of the evaluator, or "" if there isn't one.
+func (b *Board) endgameEval(eval int) (int, string) {
+	counts := b.pieceCounts()
+	if eg, ok := endgames[materialSignature(&counts)]; ok {
+		return applyEndgame(b, eg.strongWhite, eval, eg.score), eg.name
+	}
+	// The rules are about the side ahead
+	strongWhite := eval >= 0
+	for _, rule := range endgameRules {
+		if rule.match(&counts, strongWhite) {
+			return applyEndgame(b, strongWhite, eval, rule.score), rule.name
+		}
+	}
+	return eval, ""
+}
+
+// applyEndgame calls score from the strong side's point of view.
+func applyEndgame(b *Board, strongWhite bool, eval int, score endgameFunc) int {
+	if strongWhite {
+		return score(b, true, eval)
+	}
+	return -score(b, false, -eval)
+}
+
+// findPieces returns the squares of every piece of one kind.
+func (b *Board) findPieces(piece byte) []int {
+	squares := make([]int, 0, 2)
+	for idx := 11; idx < 89; idx++ {
+		if b.board[idx] == piece {
+			squares = append(squares, idx)
+		}
+	}
+	return squares
+}
+
+// kingSquare returns the square of the King of isWhite.
+func (b *Board) kingSquare(isWhite bool) int {
+	for _, idx := range b.findPieces(sidePiece('K', isWhite)) {
+		return idx
+	}
+	return 0
+}
+
+// distance is the number of King moves between two squares.
+func distance(a, b int) int {
+	ranks, files := abs(a/10-b/10), abs(a%10-b%10)
+	if ranks > files {
+		return ranks
+	}
+	return files
+}
+
+// edgeDistance is how far a square is from the centre,
+// 0 in the centre and 6 in a corner.
+func edgeDistance(idx int) int {
+	centre := func(n int) int { // 1 - 8 to 0 - 3
+		if n > 4 {
+			return n - 5
+		}
+		return 4 - n
+	}
+	return centre(idx/10) + centre(idx%10)
+}
+
+// lightSquare returns true for the light squares, h1 is light.
+func lightSquare(idx int) bool {
+	return (idx/10+idx%10)%2 == 0
+}
+
+// mateDrive rewards pushing the weak King to the edge
+// and bringing the strong King close to it.
+func (b *Board) mateDrive(strongWhite bool) int {
+	weak, strong := b.kingSquare(!strongWhite), b.kingSquare(strongWhite)
+	return 20*edgeDistance(weak) + 10*(7-distance(weak, strong))
+}
+
+// matchKXK matches a lone King against enough material to mate.
+func matchKXK(counts *[128]int, strongWhite bool) bool {
+	weakPieces := nonPawnMaterial(counts, !strongWhite) +
+		counts[sidePiece('P', !strongWhite)]
+	return weakPieces == 0 && nonPawnMaterial(counts, strongWhite) >= pieceValue('R')
+}
+
+// evaluateKXK drives the lone King to the edge for the mate.
+func evaluateKXK(b *Board, strongWhite bool, eval int) int {
+	counts := b.pieceCounts()
+	return knownWin + nonPawnMaterial(&counts, strongWhite) +
+		counts[sidePiece('P', strongWhite)]*pieceValue('P') +
+		b.mateDrive(strongWhite)
+}
+
+// evaluateKBNK drives the lone King to a corner the colour of
+// the Bishop, the only corners where the mate can be forced.
+func evaluateKBNK(b *Board, strongWhite bool, eval int) int {
+	weak := b.kingSquare(!strongWhite)
+	corners := [2]int{18, 81} // dark, a1 and h8
+	for _, bishop := range b.findPieces(sidePiece('B', strongWhite)) {
+		if lightSquare(bishop) {
+			corners = [2]int{11, 88}
+		}
+	}
+	corner := distance(weak, corners[0])
+	if d := distance(weak, corners[1]); d < corner {
+		corner = d
+	}
+	return knownWin + pieceValue('B') + pieceValue('N') +
+		b.mateDrive(strongWhite) + 40*(7-corner)
+}
+
+// evaluateKPK looks the position up in the KPK bitbase, a win
+// scores more the further the pawn is, and the rest are drawn.
+func evaluateKPK(b *Board, strongWhite bool, eval int) int {
+	pawn := b.findPieces(sidePiece('P', strongWhite))[0]
+	weak, strong := b.kingSquare(!strongWhite), b.kingSquare(strongWhite)
+	strongToMove := (b.toMove == "w") == strongWhite
+	if !kpkProbe(strongWhite, strongToMove, strong, weak, pawn) {
+		return 0
+	}
+	rank := pawn / 10
+	if !strongWhite {
+		rank = 9 - rank
+	}
+	return knownWin + pieceValue('P') + 10*rank
+}
+
+// scaleDraw scores a drawn ending.
+func scaleDraw(b *Board, strongWhite bool, eval int) int {
+	return 0
+}
+
+// matchWrongRookPawn matches a King, Bishop and rook pawns
+// on one file, against a lone King.
+func matchWrongRookPawn(counts *[128]int, strongWhite bool) bool {
+	weak := nonPawnMaterial(counts, !strongWhite) + counts[sidePiece('P', !strongWhite)]
+	return weak == 0 && counts[sidePiece('P', strongWhite)] > 0 &&
+		nonPawnMaterial(counts, strongWhite) == pieceValue('B') &&
+		counts[sidePiece('B', strongWhite)] == 1
+}
+
+// scaleWrongRookPawn draws when the Bishop can't control the
+// promotion square of the rook pawns, and the defending King
+// is there first.
+func scaleWrongRookPawn(b *Board, strongWhite bool, eval int) int {
+	pawns := b.findPieces(sidePiece('P', strongWhite))
+	file := pawns[0] % 10
+	if file != 1 && file != 8 {
+		return eval
+	}
+	for _, pawn := range pawns {
+		if pawn%10 != file {
+			return eval
+		}
+	}
+	promotion := 80 + file
+	if !strongWhite {
+		promotion = 10 + file
+	}
+	bishop := b.findPieces(sidePiece('B', strongWhite))[0]
+	weak := b.kingSquare(!strongWhite)
+	if lightSquare(bishop) != lightSquare(promotion) && distance(weak, promotion) <= 1 {
+		return 0
+	}
+	return eval
+}
+
+// matchOppositeBishops matches a Bishop each on opposite colours,
+// with only pawns besides. The squares are checked in scaling.
+func matchOppositeBishops(counts *[128]int, strongWhite bool) bool {
+	return nonPawnMaterial(counts, true) == pieceValue('B') &&
+		nonPawnMaterial(counts, false) == pieceValue('B') &&
+		counts['B'] == 1 && counts['b'] == 1
+}
+
+// scaleOppositeBishops halves the score of opposite coloured
+// bishops, and halves it again if a pawn or less ahead.
+func scaleOppositeBishops(b *Board, strongWhite bool, eval int) int {
+	if lightSquare(b.findPieces('B')[0]) == lightSquare(b.findPieces('b')[0]) {
+		return eval
+	}
+	counts := b.pieceCounts()
+	if abs(counts['P']-counts['p']) <= 1 {
+		return eval * 16 / scaleNormal
+	}
+	return eval * 32 / scaleNormal
+}
+
+// matchNoPawns matches a strong side without pawns, and at
+// most a minor piece ahead, which is hard to win.
+func matchNoPawns(counts *[128]int, strongWhite bool) bool {
+	return counts[sidePiece('P', strongWhite)] == 0 &&
+		nonPawnMaterial(counts, strongWhite)-nonPawnMaterial(counts, !strongWhite) <= pieceValue('B')
+}
+
+// scaleNoPawns scales down the score without pawns to win
+// with: a minor piece alone can't mate, and a Rook against a
+// minor piece is mostly drawn.
+func scaleNoPawns(b *Board, strongWhite bool, eval int) int {
+	counts := b.pieceCounts()
+	strong := nonPawnMaterial(&counts, strongWhite)
+	switch {
+	case strong < pieceValue('R'):
+		return 0
+	case nonPawnMaterial(&counts, !strongWhite) <= pieceValue('B'):
+		return eval * 4 / scaleNormal
+	}
+	return eval * 14 / scaleNormal
+}
@@ -0,0 +1,108 @@
+package ghess
+
+import (
+	"testing"
+)
+
+func TestMaterialSignature(t *testing.T) {
+	game := NewBoard()
+	counts := game.pieceCounts()
+	if sig := materialSignature(&counts); sig != "KQRRBBNNPPPPPPPPvKQRRBBNNPPPPPPPP" {
+		t.Error("Wrong starting signature", sig)
+	}
+	_ = game.LoadFen(`8/8/8/4k3/8/8/8/R3K3 w - - 0 60`)
+	counts = game.pieceCounts()
+	if sig := materialSignature(&counts); sig != "KRvK" {
+		t.Error("Should be KRvK", sig)
+	}
+}
+
+func TestKXK(t *testing.T) {
+	game := NewBoard()
+	_ = game.LoadFen(`7k/8/8/8/8/8/8/R3K3 w - - 0 60`)
+	corner := game.Evaluate()
+	_ = game.LoadFen(`8/8/8/4k3/8/8/8/R3K3 w - - 0 60`)
+	centre := game.Evaluate()
+	if centre < knownWin || corner <= centre {
+		t.Error("Rook should drive the King to the corner", corner, centre)
+	}
+	if trace := game.EvaluateTrace(); trace.Endgame != "KXK" {
+		t.Error("KXK not used", trace.Endgame)
+	}
+	_ = game.LoadFen(`8/8/8/4K3/8/8/8/q3k3 w - - 0 60`)
+	if eval := game.Evaluate(); eval > -knownWin {
+		t.Error("Black Queen should be winning", eval)
+	}
+}
+
+func TestKBNK(t *testing.T) {
+	game := NewBoard()
+	// Light squared Bishop on f1 mates in a8 or h1
+	_ = game.LoadFen(`k7/8/8/8/8/8/8/2N1KB2 w - - 0 60`)
+	light := game.Evaluate()
+	_ = game.LoadFen(`7k/8/8/8/8/8/8/2N1KB2 w - - 0 60`)
+	dark := game.Evaluate()
+	if light <= dark {
+		t.Error("Bishop's corner should score more", light, dark)
+	}
+}
+
+func TestKPK(t *testing.T) {
+	game := NewBoard()
+	// The King on a8 can't catch the e pawn
+	_ = game.LoadFen(`k7/8/8/4P3/8/8/8/K7 w - - 0 60`)
+	if eval := game.Evaluate(); eval < knownWin {
+		t.Error("Pawn should run in", eval)
+	}
+	_ = game.LoadFen(`8/5k2/8/4P3/8/8/8/K7 w - - 0 60`)
+	if eval := game.Evaluate(); eval >= knownWin {
+		t.Error("f7 King catches the pawn", eval)
+	}
+	// Rook pawn with the King in the corner
+	_ = game.LoadFen(`k7/8/8/P7/8/8/8/7K w - - 0 60`)
+	if eval := game.Evaluate(); eval != 0 {
+		t.Error("Rook pawn should be drawn", eval)
+	}
+}
+
+func TestDrawnEndings(t *testing.T) {
+	game := NewBoard()
+	for _, fen := range []string{
+		`8/8/8/4k3/8/8/8/1NN1K3 w - - 0 60`, // KNNK
+		`8/8/8/4k3/8/8/8/4KB2 w - - 0 60`,   // KBK
+		`8/8/8/4k3/8/8/8/4K3 w - - 0 60`,    // KK
+		`7k/8/8/7P/8/8/8/4KB2 w - - 0 60`,   // wrong rook pawn
+	} {
+		_ = game.LoadFen(fen)
+		if eval := game.Evaluate(); eval != 0 {
+			t.Error("Should be drawn", fen, eval)
+		}
+	}
+	// The right Bishop wins
+	_ = game.LoadFen(`7k/8/8/7P/8/8/8/2B1K3 w - - 0 60`)
+	if eval := game.Evaluate(); eval <= 0 {
+		t.Error("Dark Bishop controls h8", eval)
+	}
+}
+
+func TestScaleFactors(t *testing.T) {
+	game := NewBoard()
+	// c1 is dark and c8 is light
+	_ = game.LoadFen(`2b1k3/pp6/8/8/8/8/PPP5/2B1K3 w - - 0 40`)
+	trace := game.EvaluateTrace()
+	if trace.Endgame != "Opposite bishops" {
+		t.Fatal("Opposite bishops not scaled", trace.Endgame)
+	}
+	unscaled := trace.Draw
+	for _, term := range trace.terms() {
+		unscaled += term[0] - term[1]
+	}
+	if trace.Total != unscaled*16/scaleNormal {
+		t.Error("Should be a quarter", trace.Total, unscaled)
+	}
+	// Rook against Bishop
+	_ = game.LoadFen(`4k3/8/8/8/8/8/2b5/R3K3 w - - 0 60`)
+	if trace := game.EvaluateTrace(); trace.Endgame != "No pawns" || trace.Total > 50 {
+		t.Error("Rook against Bishop is mostly drawn", trace.Endgame, trace.Total)
+	}
+}
@@ -17,10 +17,6 @@ State Functions ##################################3
 ### Ideas for Evaluation:
 
 - tension
-- mobility (possible moves)
-- Double/isolated pawns
-- Open file Rook
-- Outpost knight
 - Center control
 
 
@@ -116,25 +112,87 @@ func (b *Board) queenThreaten(pos int, piece byte, isWhite bool) bool {
 
 // TODO: implement, pawnThreatensPiece
 
+// MateScore is the evaluation of a checkmate. In search the
+// number of plies to the mate is subtracted, so a shorter
+// mate always scores better than a longer one.
+const MateScore = 1000000000
+
+// maxMatePly is the longest mate a score can describe.
+const maxMatePly = 1000
+
+// mateIn returns the number of moves to mate described by an
+// evaluation, positive if White mates and negative if Black
+// mates, or 0 if the evaluation isn't a mate.
+func mateIn(eval int) int {
+	switch {
+	case eval > MateScore-maxMatePly:
+		return (MateScore - eval + 1) / 2
+	case eval < -MateScore+maxMatePly:
+		return -(MateScore + eval + 1) / 2
+	}
+	return 0
+}
+
 // See chess programming wiki:
 // http://chessprogramming.wikispaces.com/Simplified+evaluation+function
 
 // Evaluate returns score based on position.
 // When evaluating individual pieces, the boolean to pass
 // in does not mean WHOSE turn it is but rather who owns the piece.
+// The piece square tables are tapered, moving from the Middle
+// Game to the End Game tables as the pieces come off the board.
+// See EvaluateTrace for the breakdown of the score, and
+// EvalParams for evaluating with other weights.
 func (b *Board) Evaluate() int {
-	// For position, if piece,
-	var score int
+	return defaultParams.Evaluate(b)
+}
 
+// EvaluateTrace returns the score of Evaluate broken
+// down by term and by side.
+func (b *Board) EvaluateTrace() EvalTrace {
+	return defaultParams.Trace(b)
+}
+
+// Evaluator scores a position, positive for White. EvalParams
+// is the hand written evaluation, and Network a learned one.
+// Set SearchOptions.Evaluator to search with another.
+type Evaluator interface {
+	Evaluate(b *Board) int
+}
+
+// phaseScore is a Middle Game and End Game score.
+type phaseScore [2]int
+
+// add adds a Middle Game and End Game score.
+func (p *phaseScore) add(mid, end int) {
+	p[0] += mid
+	p[1] += end
+}
+
+// Evaluate scores the board with these weights, see Board.Evaluate.
+func (ep *EvalParams) Evaluate(b *Board) int {
+	return ep.Trace(b).Total
+}
+
+// Trace returns the score of Evaluate broken
+// down by term and by side.
+func (ep *EvalParams) Trace(b *Board) EvalTrace {
+	var t EvalTrace
 	if b.Checkmate {
+		// The search takes the distance to mate off
 		if b.Score == "0-1" {
-			score -= 1000000000
+			t.Total = -MateScore
+			return t
 		} else if b.Score == "1-0" {
-			score += 1000000000
+			t.Total = MateScore
+			return t
 		}
 	} else if b.Draw {
-		score -= 50000
+		t.Draw = ep.Draw
 	}
+
+	var pst, king [2]phaseScore // by side, White first
+	var phase int
 	for idx, val := range b.board {
 		// only look at 64 squares:
 
@@ -142,41 +200,45 @@ func (b *Board) Evaluate() int {
 			continue
 		}
 
-		isWhitePiece := b.isUpper(idx)
-		if isWhitePiece {
-			score += matMap[val]
-		} else {
-			score -= matMap[val]
-		}
-		switch val {
-		case 'P', 'p':
-			if isWhitePiece {
-				score += whitePawnMap[idx]
-			} else {
-				score -= blackPawnMap[idx]
-			}
-		case 'N', 'n':
-			if isWhitePiece {
-				score += whiteKnightMap[idx]
-			} else {
-				score -= blackKnightMap[idx]
-			}
-		case 'B', 'b':
-			if isWhitePiece {
-				score += whiteBishopMap[idx]
-			} else {
-				score -= blackBishopMap[idx]
-			}
-		case 'R', 'r':
-			if isWhitePiece {
-				score += whiteRookMap[idx]
-			} else {
-				score -= blackRookMap[idx]
-			}
+		side := 0
+		if !b.isUpper(idx) {
+			side = 1
 		}
+		t.Material[side] += ep.material[val]
+		phase += phaseMap[val]
+		pst[side].add(ep.pst[val][idx][0], ep.pst[val][idx][1])
+	}
+	if phase > totalPhase {
+		phase = totalPhase // early promotions
 	}
-	return score
+	t.Phase = phase
+
+	pawns := b.evaluatePawns(ep)
+	mobility, pieces := b.evaluatePieces(ep)
+	// King safety only counts in the Middle Game
+	king[0][0] = b.kingSafety(ep, true)
+	king[1][0] = b.kingSafety(ep, false)
+	for side := 0; side < 2; side++ {
+		t.PST[side] = taper(pst[side], phase)
+		t.Pawns[side] = taper(pawns[side], phase)
+		t.Mobility[side] = taper(mobility[side], phase)
+		t.Pieces[side] = taper(pieces[side], phase)
+		t.KingSafety[side] = taper(king[side], phase)
+	}
+	t.Total = t.Draw
+	for _, term := range t.terms() {
+		t.Total += term[0] - term[1]
+	}
+	if !b.Draw {
+		t.Total, t.Endgame = b.endgameEval(t.Total)
+	}
+	return t
+}
 
+// taper interpolates between the Middle Game and End Game
+// scores by the phase, the non-pawn material on the board.
+func taper(score phaseScore, phase int) int {
+	return (score[0]*phase + score[1]*(totalPhase-phase)) / totalPhase
 }
 
 /*
@@ -1,6 +1,7 @@
 package ghess
 
 import (
+	"strings"
 	"testing"
 )
 
@@ -10,3 +11,83 @@ func TestEvaluateZero(t *testing.T) {
 		t.Error("Init Position should be egal")
 	}
 }
+
+func TestMateIn(t *testing.T) {
+	if mateIn(MateScore-1) != 1 {
+		t.Error("One ply is mate in 1")
+	}
+	if mateIn(MateScore-3) != 2 {
+		t.Error("Three plies is mate in 2")
+	}
+	if mateIn(-MateScore+2) != -1 {
+		t.Error("Two plies is mate in 1 for Black")
+	}
+	if mateIn(900) != 0 {
+		t.Error("A Queen isn't mate")
+	}
+}
+
+func TestTableSquare(t *testing.T) {
+	if tableSquare(0) != 88 || tableSquare(63) != 11 {
+		t.Error("Tables start on a8 and end on h1")
+	}
+	if mirrorSquare(24) != 74 || mirrorSquare(mirrorSquare(37)) != 37 {
+		t.Error("e2 mirrors to e7")
+	}
+	// e2 and e7 pawns block the centre
+	ep := DefaultParams()
+	if ep.pst['P'][24][0] != -20 || ep.pst['p'][74][0] != -20 {
+		t.Error("Pawn tables wrong on e2 and e7",
+			ep.pst['P'][24], ep.pst['p'][74])
+	}
+}
+
+func TestTaperedKing(t *testing.T) {
+	game := NewBoard()
+	// In the End Game the King belongs in the centre
+	_ = game.LoadFen(`7k/p7/8/8/3K4/8/P7/8 w - - 0 50`)
+	centre := game.Evaluate()
+	_ = game.LoadFen(`7k/p7/8/8/8/8/P7/K7 w - - 0 50`)
+	corner := game.Evaluate()
+	if centre <= corner {
+		t.Error("End Game King should centralise", centre, corner)
+	}
+	// With all the pieces, it hides
+	_ = game.LoadFen(`rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQ1RK1 w kq - 0 1`)
+	castled := game.Evaluate()
+	_ = game.LoadFen(`rnbqkbnr/pppppppp/8/8/8/4K3/PPPPPPPP/RNBQ1R2 w kq - 0 1`)
+	exposed := game.Evaluate()
+	if castled <= exposed {
+		t.Error("Middle Game King should hide", castled, exposed)
+	}
+}
+
+func TestEvaluateTrace(t *testing.T) {
+	game := NewBoard()
+	trace := game.EvaluateTrace()
+	for i, term := range trace.terms() {
+		if term[0] != term[1] {
+			t.Error(traceNames[i], "should be equal", term)
+		}
+	}
+	if trace.Material[0] != 24000 || trace.Phase != totalPhase {
+		t.Error("Unexpected Material or Phase", trace)
+	}
+
+	fen := "r1bqkb1r/1p3ppp/p1n2n2/3p4/8/1N1B4/PPP2PPP/RNBQ1RK1 w kq - 0 9"
+	_ = game.LoadFen(fen)
+	trace = game.EvaluateTrace()
+	if trace.Total != game.Evaluate() {
+		t.Error("Trace total doesn't match Evaluate")
+	}
+	sum := trace.Draw
+	for _, term := range trace.terms() {
+		sum += term[0] - term[1]
+	}
+	if sum != trace.Total {
+		t.Error("Terms don't add up to Total", trace)
+	}
+	if !strings.Contains(game.StringEvaluation(), "King Safety") {
+		t.Error("Missing King Safety in", game.StringEvaluation())
+	}
+}
@@ -0,0 +1,198 @@
+// Package ghess is a chess engine. This file concerns the
+// opening explorer, a tree of the positions of a database of
+// games with the moves played from each, and how they scored.
+package ghess
+
+import (
+	"errors"
+	"io"
+	"sort"
+	"sync"
+)
+
+// Explorer is an opening tree of games, queried by position.
+// Positions are matched whatever the move order. It is safe
+// to query while games are added.
+type Explorer struct {
+	plies     int
+	mu        sync.RWMutex
+	positions map[string]*explorerNode // by openingKey
+	games     int
+}
+
+// explorerNode is a position of the tree.
+type explorerNode struct {
+	results Results
+	moves   map[[2]int]*MoveStats
+}
+
+// Results are the games of a position or move, by result.
+// The percentages are filled in by Position.
+type Results struct {
+	Games int `json:"games"`
+	White int `json:"white"` // won by White
+	Draws int `json:"draws"`
+	Black int `json:"black"` // won by Black
+
+	WhitePercent float64 `json:"whitePercent"`
+	DrawPercent  float64 `json:"drawPercent"`
+	BlackPercent float64 `json:"blackPercent"`
+}
+
+// MoveStats are the games in which a move was played.
+type MoveStats struct {
+	Move string `json:"move"` // SAN, as first played
+	Orig int    `json:"orig"` // Board coordinates
+	Dest int    `json:"dest"`
+	Results
+}
+
+// ExplorerResult is the answer to a query of a position.
+type ExplorerResult struct {
+	Results
+	Opening *Opening    `json:"opening,omitempty"`
+	Moves   []MoveStats `json:"moves"` // most played first
+}
+
+// add counts a game with the result.
+func (r *Results) add(result string) {
+	r.Games++
+	switch result {
+	case "1-0":
+		r.White++
+	case "0-1":
+		r.Black++
+	default:
+		r.Draws++
+	}
+}
+
+// percent fills in the percentages.
+func (r *Results) percent() {
+	if r.Games == 0 {
+		return
+	}
+	total := float64(r.Games)
+	r.WhitePercent = 100 * float64(r.White) / total
+	r.DrawPercent = 100 * float64(r.Draws) / total
+	r.BlackPercent = 100 * float64(r.Black) / total
+}
+
+// NewExplorer returns an empty Explorer, which adds the first
+// plies of each game, or all of them if plies is 0.
+func NewExplorer(plies int) *Explorer {
+	return &Explorer{
+		plies:     plies,
+		positions: make(map[string]*explorerNode),
+	}
+}
+
+// Games returns the number of games added.
+func (e *Explorer) Games() int {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.games
+}
+
+// AddGame adds the positions and moves of a game. Games without
+// a result, or with a move that can't be played, are left out.
+func (e *Explorer) AddGame(g PgnGame) error {
+	if g.Result != "1-0" && g.Result != "0-1" && g.Result != "1/2-1/2" {
+		return errors.New("Game has no result")
+	}
+	// Replay outside the lock, it's the slow part
+	type played struct {
+		key, san   string
+		orig, dest int
+	}
+	var moves []played
+	end, err := g.Replay(e.plies, func(b *Board, orig, dest int) {
+		moves = append(moves, played{b.openingKey(), g.Moves[len(moves)], orig, dest})
+	})
+	if err != nil {
+		return err
+	}
+	final := end.openingKey()
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.games++
+	for _, m := range moves {
+		node := e.node(m.key)
+		node.results.add(g.Result)
+		stats, ok := node.moves[[2]int{m.orig, m.dest}]
+		if !ok {
+			stats = &MoveStats{Move: m.san, Orig: m.orig, Dest: m.dest}
+			node.moves[[2]int{m.orig, m.dest}] = stats
+		}
+		stats.add(g.Result)
+	}
+	e.node(final).results.add(g.Result)
+	return nil
+}
+
+// node returns the position of key, adding it if new.
+func (e *Explorer) node(key string) *explorerNode {
+	node, ok := e.positions[key]
+	if !ok {
+		node = &explorerNode{moves: make(map[[2]int]*MoveStats)}
+		e.positions[key] = node
+	}
+	return node
+}
+
+// AddPgn adds every game of a PGN file, see AddGame,
+// and returns how many were added.
+func (e *Explorer) AddPgn(r io.Reader) (int, error) {
+	games, err := ReadPgn(r)
+	if err != nil {
+		return 0, err
+	}
+	var added int
+	for _, g := range games {
+		if err := e.AddGame(g); err == nil {
+			added++
+		}
+	}
+	return added, nil
+}
+
+// Query returns the games and moves of the position of a FEN.
+func (e *Explorer) Query(fen string) (ExplorerResult, error) {
+	game := NewBoard()
+	if err := game.LoadFen(fen); err != nil {
+		return ExplorerResult{}, err
+	}
+	return e.Position(&game), nil
+}
+
+// Position returns the games and moves of the board's position.
+func (e *Explorer) Position(b *Board) ExplorerResult {
+	key := b.openingKey()
+	result := ExplorerResult{Moves: []MoveStats{}}
+	if entry, ok := ecoTable()[key]; ok {
+		result.Opening = &entry.Opening
+	}
+
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	node, ok := e.positions[key]
+	if !ok {
+		return result
+	}
+	result.Results = node.results
+	result.percent()
+	for _, stats := range node.moves {
+		m := *stats
+		m.percent()
+		result.Moves = append(result.Moves, m)
+	}
+	sort.Slice(result.Moves, func(i, j int) bool {
+		a, b := result.Moves[i], result.Moves[j]
+		if a.Games != b.Games {
+			return a.Games > b.Games
+		}
+		return a.Move < b.Move
+	})
+	return result
+}
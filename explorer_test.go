@@ -0,0 +1,57 @@
+package ghess
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestExplorer(t *testing.T) {
+	e := NewExplorer(0)
+	added, err := e.AddPgn(strings.NewReader(bookPgn + "\n1. e4 e5 *\n\n1. d4 d4 1-0\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if added != 3 || e.Games() != 3 {
+		t.Error("Games without a result or with an illegal move shouldn't be added", added, e.Games())
+	}
+	result, err := e.Query(`rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Games != 3 || result.White != 1 || result.Draws != 1 || result.Black != 1 {
+		t.Error("Start should have all 3 games", result.Results)
+	}
+	if len(result.Moves) != 2 || result.Moves[0].Move != "e4" || result.Moves[0].Games != 2 {
+		t.Fatal("e4 was played twice", result.Moves)
+	}
+	if e4 := result.Moves[0]; e4.WhitePercent != 50 || e4.BlackPercent != 50 || e4.Orig != 24 {
+		t.Error("e4 scored a win and a loss", e4)
+	}
+	// After 1. e4 e5 the game ended
+	result, _ = e.Query(`rnbqkbnr/pppp1ppp/8/4p3/4P3/8/PPPP1PPP/RNBQKBNR w KQkq e6 0 2`)
+	if result.Games != 1 || len(result.Moves) != 0 || result.Opening.ECO != "C20" {
+		t.Error("1. e4 e5 was reached once", result)
+	}
+	if _, err := e.Query("not a fen"); err == nil {
+		t.Error("Bad FEN should be an error")
+	}
+}
+
+func TestExplorerTransposition(t *testing.T) {
+	e := NewExplorer(4)
+	_ = e.AddGame(PgnGame{Moves: []string{"Nf3", "d5", "d4", "Nf6", "Bf4"}, Result: "1-0"})
+	_ = e.AddGame(PgnGame{Moves: []string{"d4", "d5", "Nf3", "Nf6", "c4"}, Result: "0-1"})
+	game := NewBoard()
+	for _, move := range []string{"d4", "Nf6", "Nf3", "d5"} {
+		_ = game.ParseMove(move)
+	}
+	result := e.Position(&game)
+	if result.Games != 2 || len(result.Moves) != 0 {
+		t.Error("Both games reach the position, and stop there", result)
+	}
+	data, err := json.Marshal(result)
+	if err != nil || !strings.Contains(string(data), `"whitePercent":50`) {
+		t.Error("Should serve as JSON", string(data), err)
+	}
+}
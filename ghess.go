@@ -262,6 +262,8 @@ Loop:
 				fmt.Print(board.String())
 			case input == "/print":
 				fmt.Print(board.String())
+			case input == "/eval":
+				fmt.Print(board.StringEvaluation())
 			default:
 				fmt.Println("Mysterious input")
 			}
@@ -0,0 +1,170 @@
+// Package ghess is a chess engine. This file concerns the
+// statistics reported while searching.
+package ghess
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// SearchInfo is a snapshot of a search in progress. It is
+// passed to SearchOptions.Info after every completed depth
+// of IterativeDeepening, and whenever a new best move is
+// found at the root. Only the Complete ones are of the move
+// IterativeDeepening returns, a deeper iteration may be stopped.
+type SearchInfo struct {
+	Depth    int           // depth being searched, in plies
+	SelDepth int           // deepest ply reached
+	Nodes    int64         // positions visited
+	NPS      int64         // nodes per second
+	Time     time.Duration // since the search began
+	HashFull int           // permille of pvHash in use
+	Score    int           // positive for White advantage
+	Mate     int           // moves to mate, or 0
+	Move     [2]int        // best move so far
+	PV       [][2]int      // principal variation
+	Complete bool          // the depth was searched in full
+}
+
+// String returns the info in the style of a UCI info line.
+func (i SearchInfo) String() string {
+	score := fmt.Sprintf("cp %d", i.Score)
+	if i.Mate != 0 {
+		score = fmt.Sprintf("mate %d", i.Mate)
+	}
+	pv := ""
+	for _, move := range i.PV {
+		pv += " " + PieceMap[move[0]] + PieceMap[move[1]]
+	}
+	return fmt.Sprintf("depth %d seldepth %d score %s nodes %d nps %d hashfull %d time %d pv%s",
+		i.Depth, i.SelDepth, score, i.Nodes, i.NPS, i.HashFull,
+		i.Time.Nanoseconds()/1e6, pv)
+}
+
+// searchStats counts nodes for a search, it is shared by
+// every State searched with the same SearchOptions.
+type searchStats struct {
+	sync.Mutex           // serializes calls to Info
+	start      time.Time // when the search began
+	nodes      int64
+	seldepth   int64
+	depth      int  // depth of the current iteration
+	iterating  bool // reset by IterativeDeepening, not the root
+}
+
+// reset starts counting for a new search of depth plies.
+func (st *searchStats) reset(depth int) {
+	st.Lock()
+	st.start = time.Now()
+	atomic.StoreInt64(&st.nodes, 0)
+	atomic.StoreInt64(&st.seldepth, 0)
+	st.depth = depth
+	st.Unlock()
+}
+
+// count adds a node visited at ply.
+func (o *SearchOptions) count(ply int) {
+	if o.stats == nil {
+		return
+	}
+	atomic.AddInt64(&o.stats.nodes, 1)
+	for {
+		sel := atomic.LoadInt64(&o.stats.seldepth)
+		if int64(ply) <= sel ||
+			atomic.CompareAndSwapInt64(&o.stats.seldepth, sel, int64(ply)) {
+			return
+		}
+	}
+}
+
+// startSearch resets the statistics at the root of
+// MiniMaxPruning, unless IterativeDeepening already has.
+func (o *SearchOptions) startSearch(terminal int) {
+	if o.stats == nil || o.stats.iterating {
+		return
+	}
+	o.stats.reset(terminal)
+}
+
+// report passes the SearchInfo for best to the Info callback,
+// complete when IterativeDeepening has finished the depth.
+func (o *SearchOptions) report(best State, complete bool) {
+	if o.Info == nil || o.stats == nil {
+		return
+	}
+	st := o.stats
+	st.Lock()
+	defer st.Unlock()
+	elapsed := time.Since(st.start)
+	nodes := atomic.LoadInt64(&st.nodes)
+	var nps int64
+	if elapsed > 0 {
+		nps = int64(float64(nodes) / elapsed.Seconds())
+	}
+	o.Info(SearchInfo{
+		Depth:    st.depth,
+		SelDepth: int(atomic.LoadInt64(&st.seldepth)),
+		Nodes:    nodes,
+		NPS:      nps,
+		Time:     elapsed,
+		HashFull: pvHash.len() * 1000 / pvTableSize,
+		Score:    best.eval,
+		Mate:     best.Mate(),
+		Move:     best.Init,
+		PV:       best.PV(),
+		Complete: complete,
+	})
+}
+
+// IterativeDeepening searches s at depth 1, 2, up to terminal,
+// each iteration ordering the root moves by the last one.
+// SearchOptions.Info, if set, is called after every depth.
+// It returns the State found by the deepest search, which
+// when stopped is the deepest one finished; stopped before
+// depth 1 finishes, it returns ErrStopped.
+func IterativeDeepening(terminal int, s State) (State, error) {
+	if s.opts == nil || s.opts.stats == nil {
+		var opts SearchOptions
+		if s.opts != nil {
+			opts = *s.opts
+		}
+		s.SetOptions(opts)
+	}
+	st := s.opts.stats
+	st.reset(1)
+	st.iterating = true
+	defer func() { st.iterating = false }()
+
+	var best State
+	for depth := 1; depth <= terminal; depth++ {
+		st.Lock()
+		st.depth = depth
+		st.Unlock()
+		found, err := MiniMaxPruning(0, depth, s)
+		if err == ErrStopped && depth > 1 {
+			return best, nil
+		} else if err != nil {
+			return found, err
+		}
+		best = found
+		if best.board == nil {
+			// From the opening dictionary
+			return best, nil
+		}
+		s.opts.report(best, true)
+		if best.Mate() != 0 && depth >= 2*abs(best.Mate())-1 {
+			// Searching deeper won't find a shorter mate
+			break
+		}
+	}
+	return best, nil
+}
+
+func abs(a int) int {
+	if a < 0 {
+		return -a
+	}
+	return a
+}
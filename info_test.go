@@ -0,0 +1,99 @@
+package ghess
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestIterativeDeepening(t *testing.T) {
+	game := NewBoard()
+	fen := "r1bqkb1r/1p3ppp/p1n2n2/3p4/8/1N1B4/PPP2PPP/RNBQ1RK1 w kq - 0 9"
+	_ = game.LoadFen(fen)
+	infos := make([]SearchInfo, 0)
+	s := GetState(&game)
+	s.SetOptions(SearchOptions{
+		Info: func(info SearchInfo) {
+			infos = append(infos, info)
+		},
+	})
+	best, err := IterativeDeepening(3, s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	plain, _ := MiniMaxPruning(0, 3, GetState(&game))
+	if best.eval != plain.eval {
+		t.Error("Iterative deepening scores", best.eval,
+			"expected", plain.eval)
+	}
+	if len(infos) < 3 {
+		t.Fatal("Expected an info per depth, got", len(infos))
+	}
+	last := infos[len(infos)-1]
+	if last.Depth != 3 || last.SelDepth != 3 {
+		t.Error("Last info should be depth 3", last)
+	}
+	if last.Nodes < 1 || last.Move != best.Init {
+		t.Error("Unexpected info", last)
+	}
+	if len(last.PV) != 3 {
+		t.Error("Expected a three ply variation", last.PV)
+	}
+	if !last.Complete {
+		t.Error("Last info should be of the finished depth", last)
+	}
+	if !strings.HasPrefix(last.String(), "depth 3 seldepth 3 score cp") {
+		t.Error("Unexpected info string", last)
+	}
+}
+
+func TestIterativeDeepeningStopped(t *testing.T) {
+	game := NewBoard()
+	fen := "r1bqkb1r/1p3ppp/p1n2n2/3p4/8/1N1B4/PPP2PPP/RNBQ1RK1 w kq - 0 9"
+	_ = game.LoadFen(fen)
+	stop := make(chan struct{})
+	var complete SearchInfo
+	stopped := false
+	s := GetState(&game)
+	s.SetOptions(SearchOptions{
+		Stop: stop,
+		Info: func(info SearchInfo) {
+			if info.Complete {
+				complete = info
+			} else if info.Depth == 3 && !stopped {
+				close(stop) // on the first best move of depth 3
+				stopped = true
+			}
+		},
+	})
+	best, err := IterativeDeepening(5, s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !stopped || complete.Depth != 2 || complete.Move != best.Init || complete.Score != best.eval {
+		t.Error("Stopped at depth 3, the complete info should be of depth 2", complete, best)
+	}
+}
+
+func TestIterativeDeepeningMate(t *testing.T) {
+	game := NewBoard()
+	fen := `4k3/8/8/8/8/7r/6r1/1K6 b - - 0 2`
+	_ = game.LoadFen(fen)
+	var last SearchInfo
+	s := GetState(&game)
+	s.SetOptions(SearchOptions{
+		Info: func(info SearchInfo) {
+			last = info
+		},
+	})
+	best, err := IterativeDeepening(5, s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Stops as soon as the mate in one is found
+	if best.Mate() != -1 || last.Depth != 1 {
+		t.Error("Expected mate in 1 at depth 1", last)
+	}
+	if !strings.Contains(last.String(), "score mate -1") {
+		t.Error("Unexpected info string", last)
+	}
+}
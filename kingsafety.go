@@ -0,0 +1,118 @@
+// Package ghess is a chess engine. This file concerns the
+// evaluation of King safety.
+package ghess
+
+// attacks returns true if the piece on orig attacks target,
+// with the same validation as Tension.
+func (b *Board) attacks(orig, target int) bool {
+	var e error
+	switch b.board[orig] {
+	case 'P':
+		return target-orig == 9 || target-orig == 11
+	case 'p':
+		return orig-target == 9 || orig-target == 11
+	case 'N', 'n':
+		e = b.validKnight(orig, target)
+	case 'B', 'b':
+		e = b.validBishop(orig, target)
+	case 'R', 'r':
+		e = b.validRook(orig, target)
+	case 'Q', 'q':
+		e = b.validQueen(orig, target)
+	case 'K', 'k':
+		e = b.validKing(orig, target, false)
+	default:
+		return false
+	}
+	return e == nil
+}
+
+// kingSafety returns the Middle Game score of the King of
+// isWhite, from its own point of view: the pawn shelter, the
+// enemy pawn storm and the enemy pieces attacking the squares
+// around it. The End Game ignores it, as the King should be
+// out and about.
+func (b *Board) kingSafety(ep *EvalParams, isWhite bool) int {
+	king, own, enemy := byte('K'), byte('P'), byte('p')
+	forward := 10
+	if !isWhite {
+		king, own, enemy = 'k', 'p', 'P'
+		forward = -10
+	}
+	var kingIdx int
+	for idx := 11; idx < 89; idx++ {
+		if b.board[idx] == king {
+			kingIdx = idx
+			break
+		}
+	}
+	if kingIdx == 0 {
+		return 0
+	}
+
+	var score int
+	// Pawn shelter and storm on the King's and adjacent files
+	for _, file := range [3]int{kingIdx - 1, kingIdx, kingIdx + 1} {
+		if !onBoard(file) {
+			continue
+		}
+		// The nearest pawns up the file, 0 if there are none,
+		// and enemy pawns too far to storm don't count
+		shelterDist, stormDist := 0, 0
+		for dist, sq := 1, file+forward; onBoard(sq); dist, sq = dist+1, sq+forward {
+			switch b.board[sq] {
+			case own:
+				if shelterDist == 0 {
+					shelterDist = dist
+				}
+			case enemy:
+				if stormDist == 0 && dist < len(ep.PawnStorm) {
+					stormDist = dist
+				}
+			}
+		}
+		switch {
+		case shelterDist > 0 && shelterDist < len(ep.KingShelter):
+			score += ep.KingShelter[shelterDist]
+		case shelterDist == 0 && stormDist == 0:
+			score += ep.KingNoPawn + ep.KingOpenFile
+		default:
+			score += ep.KingNoPawn // too far to shelter
+		}
+		if stormDist > 0 {
+			score += ep.PawnStorm[stormDist]
+		}
+	}
+
+	// Enemy pieces attacking the King zone
+	var units, attackers int
+	for idx := 11; idx < 89; idx++ {
+		val := b.board[idx]
+		if val == '.' || val == ' ' {
+			continue
+		}
+		weight := ep.kingAttack[ByteToUpper[val]]
+		if weight == 0 || b.isUpper(idx) == isWhite {
+			continue
+		}
+		hits := 0
+		for _, move := range royalMoves {
+			sq := kingIdx + move
+			if onBoard(sq) && sq != idx && b.attacks(idx, sq) {
+				hits++
+			}
+		}
+		if hits > 0 {
+			attackers++
+			units += weight * hits
+		}
+	}
+	if attackers > 1 {
+		danger := units * units
+		if danger > ep.KingDangerMax {
+			danger = ep.KingDangerMax
+		}
+		score -= danger
+	}
+	return score
+}
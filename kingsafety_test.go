@@ -0,0 +1,61 @@
+package ghess
+
+import (
+	"testing"
+)
+
+func TestKingShelter(t *testing.T) {
+	game := NewBoard()
+	_ = game.LoadFen(`r4rk1/ppp2ppp/8/8/8/8/PPP2PPP/R4RK1 w - - 0 20`)
+	sheltered := game.kingSafety(defaultParams, true)
+	if sheltered != game.kingSafety(defaultParams, false) {
+		t.Error("Symmetric Kings should be equally safe")
+	}
+	// Pawns pushed away from the King
+	_ = game.LoadFen(`r4rk1/ppp2ppp/8/8/6PP/5P2/PPP5/R4RK1 w - - 0 20`)
+	if game.kingSafety(defaultParams, true) >= sheltered {
+		t.Error("Pushed pawns should shelter less")
+	}
+	// Black pawns storming the White King
+	_ = game.LoadFen(`r4rk1/ppp5/8/8/8/5ppp/PPP2PPP/R4RK1 w - - 0 20`)
+	if game.kingSafety(defaultParams, true) >= sheltered {
+		t.Error("Pawn storm should be dangerous")
+	}
+}
+
+func TestKingShelterWeights(t *testing.T) {
+	// Weights tuned to 0, or a storm tuned positive, still
+	// count the pawns
+	ep := *defaultParams
+	ep.KingShelter = [3]int{0, 0, 0}
+	ep.PawnStorm = [4]int{0, 5, 5, 5}
+	game := NewBoard()
+	_ = game.LoadFen(`r4rk1/ppp2ppp/8/8/8/8/PPP2PPP/R4RK1 w - - 0 20`)
+	if safety := game.kingSafety(&ep, true); safety != 0 {
+		t.Error("Shelter weighed 0 is no penalty", safety)
+	}
+	_ = game.LoadFen(`r4rk1/ppp5/8/8/8/5ppp/PPP2PPP/R4RK1 w - - 0 20`)
+	if safety := game.kingSafety(&ep, true); safety != 15 {
+		t.Error("Each storming pawn should count 5", safety)
+	}
+	// A storming pawn too far away leaves the file open
+	_ = game.LoadFen(`r4rk1/ppp3p1/8/8/8/8/PPP2P1P/R4RK1 w - - 0 20`)
+	if safety := game.kingSafety(&ep, true); safety != ep.KingNoPawn+ep.KingOpenFile {
+		t.Error("The g-file should be open", safety)
+	}
+}
+
+func TestKingAttack(t *testing.T) {
+	game := NewBoard()
+	_ = game.LoadFen(`r4rk1/ppp2ppp/8/8/8/8/PPP2PPP/R4RK1 w - - 0 20`)
+	quiet := game.kingSafety(defaultParams, false)
+	// Queen and Knight swarming g7 and h7
+	_ = game.LoadFen(`r4rk1/ppp2ppp/7Q/6N1/8/8/PPP2PPP/R4RK1 w - - 0 20`)
+	attacked := game.kingSafety(defaultParams, false)
+	if attacked >= quiet {
+		t.Error("Attacked King should be less safe", attacked, quiet)
+	}
+	if !game.attacks(52, 71) || !game.attacks(61, 72) {
+		t.Error("Knight and Queen attack g7 and h7")
+	}
+}
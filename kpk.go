@@ -0,0 +1,197 @@
+// Package ghess is a chess engine. This file concerns the
+// King and pawn against King bitbase, generated by retrograde
+// analysis the first time a KPK position is evaluated.
+package ghess
+
+import (
+	"sync"
+)
+
+// The bitbase has White with the pawn, on the a to d files,
+// which covers the rest by mirroring. Squares are 0 - 63,
+// a1 to h1 then up the ranks.
+const kpkSize = 2 * 64 * 64 * 24 // side to move, Kings, pawn
+
+// Results of a position while generating, as flags so
+// the results of all the moves can be or'ed together.
+const (
+	kpkInvalid = 0
+	kpkUnknown = 1 << iota
+	kpkDraw
+	kpkWin
+)
+
+var (
+	kpkOnce sync.Once
+	kpkWins []uint64 // one bit per position, set if White wins
+)
+
+// kpkIndex returns the index of a position, the pawn
+// on the a to d files and the 2nd to 7th ranks.
+func kpkIndex(whiteToMove bool, wk, bk, pawn int) int {
+	stm := 0
+	if !whiteToMove {
+		stm = 1
+	}
+	p := (pawn/8-1)*4 + pawn%8
+	return ((stm*64+wk)*64+bk)*24 + p
+}
+
+// kpkDistance is the number of King moves between two squares.
+func kpkDistance(a, b int) int {
+	ranks, files := abs(a/8-b/8), abs(a%8-b%8)
+	if ranks > files {
+		return ranks
+	}
+	return files
+}
+
+// kpkKingMoves returns the squares around sq.
+func kpkKingMoves(sq int) []int {
+	moves := make([]int, 0, 8)
+	for dr := -1; dr <= 1; dr++ {
+		for df := -1; df <= 1; df++ {
+			r, f := sq/8+dr, sq%8+df
+			if (dr != 0 || df != 0) && r >= 0 && r < 8 && f >= 0 && f < 8 {
+				moves = append(moves, r*8+f)
+			}
+		}
+	}
+	return moves
+}
+
+// kpkPawnAttacks returns true if the White pawn attacks sq.
+func kpkPawnAttacks(pawn, sq int) bool {
+	return sq/8 == pawn/8+1 && abs(sq%8-pawn%8) == 1
+}
+
+// kpkInit classifies the positions which are known without
+// looking at any moves.
+func kpkInit(whiteToMove bool, wk, bk, pawn int) byte {
+	promotion := pawn + 8
+	switch {
+	case kpkDistance(wk, bk) <= 1 || wk == pawn || bk == pawn ||
+		whiteToMove && kpkPawnAttacks(pawn, bk):
+		return kpkInvalid
+	case whiteToMove && pawn/8 == 6 && wk != promotion && bk != promotion &&
+		(kpkDistance(bk, promotion) > 1 || kpkDistance(wk, promotion) == 1):
+		return kpkWin // promotes safely
+	case !whiteToMove && len(kpkBlackMoves(wk, bk, pawn)) == 0:
+		return kpkDraw // stalemate
+	case !whiteToMove && kpkDistance(bk, pawn) == 1 && kpkDistance(wk, pawn) > 1:
+		return kpkDraw // the pawn is lost
+	}
+	return kpkUnknown
+}
+
+// kpkBlackMoves returns the squares the Black King can go to,
+// the pawn's square included.
+func kpkBlackMoves(wk, bk, pawn int) []int {
+	moves := make([]int, 0, 8)
+	for _, to := range kpkKingMoves(bk) {
+		if kpkDistance(to, wk) > 1 && !kpkPawnAttacks(pawn, to) {
+			moves = append(moves, to)
+		}
+	}
+	return moves
+}
+
+// kpkClassify looks at the moves of an unknown position. White
+// wins if a move wins, Black draws if a move draws, and the
+// position stays unknown while a move is.
+func kpkClassify(db []byte, whiteToMove bool, wk, bk, pawn int) byte {
+	var r byte
+	if whiteToMove {
+		for _, to := range kpkKingMoves(wk) {
+			if to != pawn && kpkDistance(to, bk) > 1 {
+				r |= db[kpkIndex(false, to, bk, pawn)]
+			}
+		}
+		if push := pawn + 8; pawn/8 < 6 && push != wk && push != bk {
+			r |= db[kpkIndex(false, wk, bk, push)]
+			if double := push + 8; pawn/8 == 1 && double != wk && double != bk {
+				r |= db[kpkIndex(false, wk, bk, double)]
+			}
+		}
+		switch {
+		case r&kpkWin != 0:
+			return kpkWin
+		case r&kpkUnknown != 0:
+			return kpkUnknown
+		}
+		return kpkDraw
+	}
+	for _, to := range kpkBlackMoves(wk, bk, pawn) {
+		// Taking the pawn is an invalid index, known as a draw
+		r |= db[kpkIndex(true, wk, to, pawn)]
+	}
+	switch {
+	case r&kpkDraw != 0:
+		return kpkDraw
+	case r&kpkUnknown != 0:
+		return kpkUnknown
+	}
+	return kpkWin
+}
+
+// generateKPK builds the bitbase, going over the unknown
+// positions until none change. Those left are draws.
+func generateKPK() []uint64 {
+	db := make([]byte, kpkSize)
+	type position struct {
+		whiteToMove  bool
+		wk, bk, pawn int
+	}
+	positions := make([]position, kpkSize)
+	for idx := range db {
+		p := idx % 24
+		pos := position{
+			whiteToMove: idx/(24*64*64) == 0,
+			wk:          idx / (24 * 64) % 64,
+			bk:          idx / 24 % 64,
+			pawn:        (p/4+1)*8 + p%4,
+		}
+		positions[idx] = pos
+		db[idx] = kpkInit(pos.whiteToMove, pos.wk, pos.bk, pos.pawn)
+	}
+	for changed := true; changed; {
+		changed = false
+		for idx, pos := range positions {
+			if db[idx] != kpkUnknown {
+				continue
+			}
+			if r := kpkClassify(db, pos.whiteToMove, pos.wk, pos.bk, pos.pawn); r != kpkUnknown {
+				db[idx] = r
+				changed = true
+			}
+		}
+	}
+	wins := make([]uint64, kpkSize/64)
+	for idx, r := range db {
+		if r == kpkWin {
+			wins[idx/64] |= 1 << uint(idx%64)
+		}
+	}
+	return wins
+}
+
+// kpkProbe returns true if the side with the pawn wins. The
+// squares are Board coordinates, and strongWhite is the colour
+// of the pawn.
+func kpkProbe(strongWhite, strongToMove bool, strongKing, weakKing, pawn int) bool {
+	kpkOnce.Do(func() {
+		kpkWins = generateKPK()
+	})
+	square := func(idx int) int {
+		rank, file := idx/10-1, 8-idx%10
+		if !strongWhite {
+			rank = 7 - rank
+		}
+		if pawn%10 < 5 { // e to h file, mirror to a to d
+			file = 7 - file
+		}
+		return rank*8 + file
+	}
+	idx := kpkIndex(strongToMove, square(strongKing), square(weakKing), square(pawn))
+	return kpkWins[idx/64]&(1<<uint(idx%64)) != 0
+}
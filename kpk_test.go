@@ -0,0 +1,60 @@
+package ghess
+
+import (
+	"testing"
+)
+
+func TestKPKBitbase(t *testing.T) {
+	game := NewBoard()
+	for fen, win := range map[string]bool{
+		// Rule of the square
+		`k7/8/8/4P3/8/8/8/K7 w - - 0 60`:  true,
+		`8/5k2/8/4P3/8/8/8/K7 w - - 0 60`: false,
+		// Opposition, who moves decides it
+		`8/4k3/8/4K3/4P3/8/8/8 b - - 0 60`: true,
+		`8/4k3/8/4K3/4P3/8/8/8 w - - 0 60`: false,
+		// The King on a key square wins either way
+		`4k3/8/4K3/8/4P3/8/8/8 w - - 0 60`: true,
+		`4k3/8/4K3/8/4P3/8/8/8 b - - 0 60`: true,
+		// Rook pawns
+		`k7/8/8/P7/8/8/8/7K w - - 0 60`:   false,
+		`7k/8/6K1/7P/8/8/8/8 w - - 0 60`:  false,
+		`8/8/1K6/P7/8/8/8/6k1 w - - 0 60`: true,
+		// Black with the pawn, the opposition mirrored
+		`4K3/8/8/8/8/8/8/4k3 w - - 0 60`:   false,
+		`8/8/8/4p3/4k3/8/4K3/8 w - - 0 60`: true,
+		`8/8/8/4p3/4k3/8/4K3/8 b - - 0 60`: false,
+	} {
+		if err := game.LoadFen(fen); err != nil {
+			t.Fatal(fen, err)
+		}
+		eval := game.Evaluate()
+		if won := abs(eval) >= knownWin; won != win {
+			t.Error("Wrong KPK result", fen, eval)
+		}
+	}
+}
+
+func TestKPKIndex(t *testing.T) {
+	seen := make(map[int]bool)
+	for _, white := range [2]bool{true, false} {
+		for wk := 0; wk < 64; wk++ {
+			for pawn := 8; pawn < 56; pawn++ {
+				if pawn%8 > 3 {
+					continue
+				}
+				idx := kpkIndex(white, wk, 0, pawn)
+				if idx < 0 || idx >= kpkSize || seen[idx] {
+					t.Fatal("Bad index", white, wk, pawn, idx)
+				}
+				seen[idx] = true
+			}
+		}
+	}
+}
+
+func BenchmarkGenerateKPK(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		generateKPK()
+	}
+}
@@ -3,18 +3,87 @@ package ghess
 import (
 	"errors"
 	"fmt"
+	"sort"
 	"strings"
 )
 
-// Principal Variation Search
-
-var pvHash map[[120]byte]int = make(map[[120]byte]int)
-var pvMap map[Board][2]int = make(map[Board][2]int)
-
 /*
 MiniMax implementation ###########################################
 */
 
+// SearchOptions turns on the optional pruning in MiniMaxPruning.
+// The zero value searches every move to full depth, so engines
+// with different options can be played against each other.
+type SearchOptions struct {
+	NullMove          bool // Null-move pruning
+	NullReduction     int  // Plies to reduce the null move search, R
+	LateMoveReduction bool // Reduce quiet moves late in the ordering
+	LateMoveIndex     int  // How many moves are searched before reducing
+	Threads           int  // Goroutines splitting the root, 0 or 1 for one
+	NoDictionary      bool // Don't look up the opening dictionary or Book at the root
+
+	// Params are the evaluation weights, nil for DefaultParams.
+	// They're prepared when the search starts if they weren't,
+	// and a search with weights Prepare rejects is an error.
+	Params *EvalParams
+	// Evaluator scores the positions of the search in place of
+	// Params, such as a Network. Nil evaluates with Params.
+	Evaluator Evaluator
+	// Book is played from at the root in place of the opening
+	// dictionary, nil for the dictionary. BookRandom picks the
+	// moves at random by weight, rather than the heaviest.
+	Book       *Book
+	BookRandom bool
+	// Tablebase picks the root move and ends the search in
+	// positions it knows, nil for none.
+	Tablebase Tablebase
+
+	// Info is called with the progress of the search, see SearchInfo.
+	Info func(SearchInfo)
+	// Stop ends the search with ErrStopped when closed, nil
+	// searches to the end. IterativeDeepening then returns
+	// the deepest search it finished.
+	Stop  <-chan struct{}
+	stats *searchStats
+}
+
+// ErrStopped is returned by a search ended by SearchOptions.Stop.
+var ErrStopped = errors.New("Search stopped")
+
+// stopped returns true once Stop is closed.
+func (o *SearchOptions) stopped() bool {
+	select {
+	case <-o.Stop:
+		return true
+	default:
+		return false
+	}
+}
+
+// DefaultOptions returns SearchOptions with null-move
+// pruning and late move reductions turned on.
+func DefaultOptions() SearchOptions {
+	return SearchOptions{
+		NullMove:          true,
+		NullReduction:     2,
+		LateMoveReduction: true,
+		LateMoveIndex:     3,
+	}
+}
+
+// evaluator returns the Evaluator of the search.
+func (o *SearchOptions) evaluator() Evaluator {
+	switch {
+	case o == nil:
+		return defaultParams
+	case o.Evaluator != nil:
+		return o.Evaluator
+	case o.Params != nil:
+		return o.Params
+	}
+	return defaultParams
+}
+
 // State struct holds a board position,
 // the move that got there, and the evaluation.
 // Init is the move which began a certain branch of the tree.
@@ -26,11 +95,52 @@ type State struct {
 	alpha  int
 	beta   int
 	parent *State
+	move   [2]int         // the last move, which got to this position
+	ply    int            // distance from the root
+	null   bool           // reached by passing the turn
+	opts   *SearchOptions // nil for plain alpha beta
+}
+
+// SetOptions sets the SearchOptions used when searching
+// from this State.
+func (s *State) SetOptions(opts SearchOptions) {
+	opts.stats = &searchStats{}
+	s.opts = &opts
 }
 
 // String returns some basic info of a State.
 func (s State) String() string {
-	return fmt.Sprintf("\nScore: %d\nFrom Move: %d, %d", s.eval, s.Init[0], s.Init[1])
+	info := fmt.Sprintf("\nScore: %d\nFrom Move: %d, %d", s.eval, s.Init[0], s.Init[1])
+	if mate := s.Mate(); mate != 0 {
+		info += fmt.Sprintf("\nMate in %d", mate)
+	}
+	return info
+}
+
+// Mate returns the number of moves to a forced mate found by
+// the search, positive if White mates and negative if Black
+// mates. It returns 0 if there is no mate.
+func (s State) Mate() int {
+	return mateIn(s.eval)
+}
+
+// PV returns the principal variation, the moves from the
+// root which led to this State. A variation which went
+// through a null move stops before it.
+func (s State) PV() [][2]int {
+	line := make([][2]int, 0)
+	for st := &s; st != nil && st.parent != nil; st = st.parent {
+		if st.null {
+			line = line[:0]
+			continue
+		}
+		line = append(line, st.move)
+	}
+	// Reverse, the root move is first
+	for i, j := 0, len(line)-1; i < j; i, j = i+1, j-1 {
+		line[i], line[j] = line[j], line[i]
+	}
+	return line
 }
 
 // States are a slice of State structs.
@@ -52,6 +162,11 @@ func GetState(b *Board) State {
 // TryState takes in a *Board and valid move and returns
 // a State struct.
 func TryState(b *Board, o, d int) (State, error) {
+	return tryState(b, o, d, defaultParams)
+}
+
+// tryState is TryState evaluating with e.
+func tryState(b *Board, o, d int, e Evaluator) (State, error) {
 	state := State{}
 	possible := CopyBoard(b)
 	err := possible.Move(o, d)
@@ -62,7 +177,7 @@ func TryState(b *Board, o, d int) (State, error) {
 		return state, err
 	}
 	state.board = possible
-	state.eval = possible.Evaluate()
+	state.eval = e.Evaluate(possible)
 	return state, nil
 }
 
@@ -71,8 +186,9 @@ func TryState(b *Board, o, d int) (State, error) {
 func GetPossibleStates(state State) (States, error) {
 	states := make(States, 0)
 	origs, dests := state.board.SearchValid()
+	e := state.opts.evaluator()
 	for i := 0; i < len(origs); i++ {
-		s, err := TryState(state.board, origs[i], dests[i])
+		s, err := tryState(state.board, origs[i], dests[i], e)
 		if err != nil {
 			return states, err
 		}
@@ -83,6 +199,18 @@ func GetPossibleStates(state State) (States, error) {
 				state.Init[0], state.Init[1]
 		}
 		s.isMax = state.isMax // Basically is White
+		s.move = [2]int{origs[i], dests[i]}
+		s.opts = state.opts
+		s.parent = &state // for walking back the variation
+		s.ply = state.ply + 1
+		if s.board.Checkmate {
+			// Prefer the quicker mate
+			if s.eval > 0 {
+				s.eval -= s.ply
+			} else {
+				s.eval += s.ply
+			}
+		}
 		// Add parent state?
 		states = append(states, s)
 	}
@@ -92,10 +220,7 @@ func GetPossibleStates(state State) (States, error) {
 // DictionaryAttack looks up common openings
 // for less stupid opening moves.
 func DictionaryAttack(s State) (State, error) {
-	position := s.board.Position()
-	// I don't need castling empassant or move number
-	posits := strings.Split(position, " ")
-	key := posits[0] + " " + posits[1]
+	key := s.board.dictionaryKey()
 	// Check if opening exists
 	if val, ok := dict[key]; ok {
 		state := State{Init: val}
@@ -105,13 +230,22 @@ func DictionaryAttack(s State) (State, error) {
 	return s, errors.New("No Dictionary Attack Found")
 }
 
+// dictionaryKey returns the key of the position in the
+// opening dictionary, the pieces and the player to move.
+func (b *Board) dictionaryKey() string {
+	position := b.Position()
+	// I don't need castling empassant or move number
+	posits := strings.Split(position, " ")
+	return posits[0] + " " + posits[1]
+}
+
 // The Max and Mini functions are O(n)
 
 // Max returns the state from States with
 // highest evaluation.
 func Max(states States) State {
 	var maxIdx int
-	var maxVal int = -1000
+	var maxVal int = states[0].eval
 	for idx, state := range states {
 		if state.eval > maxVal {
 			maxVal = state.eval
@@ -125,7 +259,7 @@ func Max(states States) State {
 // Lowest evaluation.
 func Min(states States) State {
 	var minIdx int
-	var minVal int = 1000
+	var minVal int = states[0].eval
 	for idx, state := range states {
 		if state.eval < minVal {
 			minVal = state.eval
@@ -148,10 +282,16 @@ func Min(states States) State {
 //     This is like a Depth First Search algorithm.
 //     Speed increase from Pruning largely depends on Move Ordering
 func MiniMaxPruning(depth, terminal int, s State) (State, error) {
+	if s.opts != nil {
+		s.opts.count(s.ply)
+		if s.opts.stopped() {
+			return s, ErrStopped
+		}
+	}
 	if depth == 0 {
 		// At first depth set Alpha and Beta values
-		s.alpha = -1000000000
-		s.beta = 1000000000
+		s.alpha = -MateScore
+		s.beta = MateScore
 
 		// Search for Max or Min Player?
 		if s.board.toMove == "w" {
@@ -160,10 +300,43 @@ func MiniMaxPruning(depth, terminal int, s State) (State, error) {
 			s.isMax = false
 		}
 
-		// At first depth check for Opening in Dictionary
-		openState, err := DictionaryAttack(s)
-		if err == nil {
-			return openState, nil
+		// Weights set by hand may not be prepared yet
+		if s.opts != nil && s.opts.Params != nil {
+			if err := s.opts.Params.prepared(); err != nil {
+				return s, err
+			}
+		}
+
+		// At first depth check for Opening in Book or Dictionary
+		if s.opts != nil && s.opts.Book != nil && !s.opts.NoDictionary {
+			if openState, err := s.bookState(); err == nil {
+				return openState, nil
+			}
+		} else if s.opts == nil || !s.opts.NoDictionary {
+			openState, err := DictionaryAttack(s)
+			if err == nil {
+				return openState, nil
+			}
+		}
+
+		if best, ok := s.tablebaseRoot(); ok {
+			return best, nil
+		}
+
+		if s.opts != nil {
+			s.opts.startSearch(terminal)
+		}
+		// Split the root moves between goroutines
+		if s.opts != nil && s.opts.Threads > 1 && terminal > 0 {
+			return s.searchParallel(terminal)
+		}
+	}
+
+	// A known result needs no more searching
+	if depth > 0 {
+		if score, ok := s.probeTablebase(); ok {
+			s.eval = score
+			return s, nil
 		}
 	}
 
@@ -180,22 +353,67 @@ func MiniMaxPruning(depth, terminal int, s State) (State, error) {
 	even := (depth % 2) == 0
 	maxNode := even == s.isMax
 
+	// Null-move pruning: pass the turn and search shallower,
+	// if the opponent still can't do better than the bound
+	// then the real moves won't either.
+	if s.nullOk(depth, terminal) {
+		nullState := s.nullState()
+		reduced := terminal - s.opts.nullReduction()
+		bestState, err := MiniMaxPruning(depth+1, reduced, nullState)
+		if err != nil {
+			return bestState, err
+		}
+		if maxNode && bestState.eval > s.beta {
+			return bestState, nil
+		}
+		if !maxNode && bestState.eval < s.alpha {
+			return bestState, nil
+		}
+	}
+
 	states, err := GetPossibleStates(s)
 	if err != nil {
 		return s, err
 	}
+	if depth == 0 && s.opts != nil {
+		// Earlier searches know the better moves
+		s.orderRoot(states, maxNode)
+	} else if s.opts != nil && s.opts.LateMoveReduction {
+		// Reductions rely on the best moves coming first
+		orderStates(states, maxNode)
+	}
 
 	// Recursively call MiniMax on all Possible States
 	var bestState State
 	var bestStates States
-	for _, state := range states {
+	for idx, state := range states {
 		state.alpha = s.alpha
 		state.beta = s.beta
-		// Increment Depth when calling MiniMax
-		bestState, err = MiniMaxPruning(depth+1, terminal, state)
+		if s.reduceOk(depth, terminal, idx, state) {
+			// Late Move Reduction: search a ply shallower,
+			// and only search again if the move improves.
+			bestState, err = MiniMaxPruning(depth+1, terminal-1, state)
+			if err != nil {
+				return bestState, err
+			}
+			if maxNode && bestState.eval > s.alpha ||
+				!maxNode && bestState.eval < s.beta {
+				bestState, err = MiniMaxPruning(depth+1, terminal, state)
+			}
+		} else {
+			// Increment Depth when calling MiniMax
+			bestState, err = MiniMaxPruning(depth+1, terminal, state)
+		}
 		if err != nil {
 			return bestState, err
 		}
+		if depth == 0 && s.opts != nil {
+			pvHash.put(state.board.board, bestState.eval)
+			if maxNode && bestState.eval > s.alpha ||
+				!maxNode && bestState.eval < s.beta {
+				s.opts.report(bestState, false)
+			}
+		}
 
 		/* Alpha Beta Pruning
 		* The trick is to update the root node's
@@ -242,6 +460,17 @@ func MiniMaxPruning(depth, terminal int, s State) (State, error) {
 		return s, nil
 	}
 
+	if depth == 0 && s.opts != nil {
+		var best State
+		if maxNode {
+			best = Max(bestStates)
+		} else {
+			best = Min(bestStates)
+		}
+		pvMap.put(s.board, best.Init)
+		return best, nil
+	}
+
 	if maxNode { // if height == Max nodes
 		return Max(bestStates), nil
 	} else { // if height == Min nodes
@@ -249,6 +478,106 @@ func MiniMaxPruning(depth, terminal int, s State) (State, error) {
 	}
 }
 
+// nullOk returns true if a null move can be tried from s.
+// Not at the root, not twice in a row, not in check and
+// not when the player has only pawns, because in pawn endgames
+// zugzwang makes passing the better move.
+func (s *State) nullOk(depth, terminal int) bool {
+	if s.opts == nil || !s.opts.NullMove {
+		return false
+	}
+	if depth == 0 || s.null || s.board.Check {
+		return false
+	}
+	if terminal-depth <= s.opts.nullReduction() {
+		return false
+	}
+	return s.board.hasPieces(s.board.toMove == "w")
+}
+
+// nullState returns a copy of s with the turn passed.
+func (s *State) nullState() State {
+	possible := CopyBoard(s.board)
+	if possible.toMove == "w" {
+		possible.toMove = "b"
+	} else {
+		possible.moves++
+		possible.toMove = "w"
+	}
+	possible.empassant = 0
+	nullState := *s
+	nullState.board = possible
+	nullState.move = [2]int{}
+	nullState.null = true
+	nullState.ply = s.ply + 1
+	nullState.parent = s
+	return nullState
+}
+
+// reduceOk returns true if the idx'th child, state, is a quiet
+// move late enough in the ordering to be searched at reduced depth.
+func (s *State) reduceOk(depth, terminal, idx int, state State) bool {
+	if s.opts == nil || !s.opts.LateMoveReduction {
+		return false
+	}
+	if depth == 0 || idx < s.opts.LateMoveIndex || terminal-depth < 3 {
+		return false
+	}
+	if s.board.Check || state.board.Check {
+		return false
+	}
+	orig, dest := state.move[0], state.move[1]
+	switch s.board.board[orig] {
+	case 'P':
+		if dest > 80 {
+			return false // promotion
+		}
+	case 'p':
+		if dest < 20 {
+			return false
+		}
+	}
+	// Captures aren't quiet, castling onto the rook isn't a capture
+	return s.board.board[dest] == '.' || s.board.isUpper(orig) == s.board.isUpper(dest)
+}
+
+// nullReduction returns R, defaults to two plies.
+func (o *SearchOptions) nullReduction() int {
+	if o.NullReduction < 1 {
+		return 2
+	}
+	return o.NullReduction
+}
+
+// orderStates sorts states with the best evaluation,
+// for the player to move, first.
+func orderStates(states States, maxNode bool) {
+	sort.SliceStable(states, func(i, j int) bool {
+		if maxNode {
+			return states[i].eval > states[j].eval
+		}
+		return states[i].eval < states[j].eval
+	})
+}
+
+// hasPieces returns true if the player owns
+// a piece other than pawns and the king.
+func (b *Board) hasPieces(isWhite bool) bool {
+	for _, val := range b.board {
+		switch val {
+		case 'N', 'B', 'R', 'Q':
+			if isWhite {
+				return true
+			}
+		case 'n', 'b', 'r', 'q':
+			if !isWhite {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 // small min, doesn't take state,
 // but it takes numbers
 func min(a, b int) int {
@@ -267,6 +596,34 @@ func max(a, b int) int {
 	}
 }
 
+// MateSearch looks for a forced mate in at most n moves
+// for the player to move, for solving chess problems.
+// Shorter mates are tried first, and null moves are never
+// used as zugzwang is common in problems. It returns an
+// error if there isn't such a mate.
+func MateSearch(n int, s State) (State, error) {
+	opts := SearchOptions{NoDictionary: true}
+	if s.opts != nil {
+		opts.Threads = s.opts.Threads
+		opts.Params = s.opts.Params
+		opts.Evaluator = s.opts.Evaluator
+		opts.Stop = s.opts.Stop
+	}
+	s.opts = &opts
+	isWhite := s.board.toMove == "w"
+	for moves := 1; moves <= n; moves++ {
+		best, err := MiniMaxPruning(0, 2*moves-1, s)
+		if err != nil {
+			return best, err
+		}
+		mate := best.Mate()
+		if isWhite && mate > 0 || !isWhite && mate < 0 {
+			return best, nil
+		}
+	}
+	return s, fmt.Errorf("No mate in %d", n)
+}
+
 // DEPRECATED
 // MiniMax is Deprecated in favor of
 // AlphaBeta Pruning Minimax
@@ -55,6 +55,54 @@ func TestCheckMateMiniMax(t *testing.T) {
 	}
 }
 
+func TestSearchOptions(t *testing.T) {
+	game := NewBoard()
+	fen := `4k3/8/8/8/8/7r/6r1/1K6 b - - 0 2`
+	err := game.LoadFen(fen)
+	if err != nil {
+		t.Error(err)
+	}
+
+	s := GetState(&game)
+	s.SetOptions(DefaultOptions())
+	nxt, err := MiniMaxPruning(0, 4, s)
+	if err != nil {
+		t.Error(err)
+	}
+
+	err = game.Move(nxt.Init[0], nxt.Init[1])
+	if err != nil {
+		t.Error(err)
+	}
+
+	if !game.Checkmate {
+		t.Error("Pruning missed the Checkmate")
+	}
+}
+
+func TestNullMoveZugzwang(t *testing.T) {
+	game := NewBoard()
+	// Only pawns, passing could be better than moving
+	fen := `8/8/4k3/4p3/4P3/4K3/8/8 w - - 0 40`
+	_ = game.LoadFen(fen)
+	s := GetState(&game)
+	s.SetOptions(DefaultOptions())
+	if s.nullOk(1, 5) {
+		t.Error("Null move in pawn endgame")
+	}
+
+	fen = `8/8/4k3/4p3/4P3/4K3/8/6N1 w - - 0 40`
+	_ = game.LoadFen(fen)
+	s = GetState(&game)
+	s.SetOptions(DefaultOptions())
+	if !s.nullOk(1, 5) {
+		t.Error("Null move should be tried with a Knight")
+	}
+	if s.nullOk(0, 5) {
+		t.Error("Null move at root")
+	}
+}
+
 /**********************************
 Chess Problems!!!
 ***********************************/
@@ -110,6 +158,57 @@ func TestChessProblemsMateInTwo(t *testing.T) {
 	}
 }
 
+func TestMateSearch(t *testing.T) {
+	game := NewBoard()
+	// Morphy Verse Duke of Brunswick
+	// 1. Qb8+ Nxb8 2. Rd8#
+	fen := `4kb1r/p2n1ppp/4q3/4p1B1/4P3/1Q6/PPP2PPP/2KR4 w k - 1 1`
+	_ = game.LoadFen(fen)
+	nxt, err := MateSearch(2, GetState(&game))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if nxt.Mate() != 2 {
+		t.Error("Expected mate in 2, got", nxt)
+	}
+	if nxt.Init != [2]int{37, 87} {
+		t.Error("Expected Qb8+, got", nxt.Init)
+	}
+
+	// Black mates in one
+	fen = `4k3/8/8/8/8/7r/6r1/1K6 b - - 0 2`
+	_ = game.LoadFen(fen)
+	nxt, err = MateSearch(2, GetState(&game))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if nxt.Mate() != -1 {
+		t.Error("Expected mate in 1 for Black, got", nxt)
+	}
+
+	game = NewBoard()
+	_, err = MateSearch(1, GetState(&game))
+	if err == nil {
+		t.Error("Found mate in the starting position")
+	}
+}
+
+func TestMatePreferShorter(t *testing.T) {
+	game := NewBoard()
+	// Rg1# mates at once, the engine shouldn't dawdle
+	fen := `4k3/8/8/8/8/7r/6r1/1K6 b - - 0 2`
+	_ = game.LoadFen(fen)
+	s := GetState(&game)
+	s.SetOptions(SearchOptions{NoDictionary: true})
+	nxt, err := MiniMaxPruning(0, 4, s)
+	if err != nil {
+		t.Error(err)
+	}
+	if nxt.Mate() != -1 {
+		t.Error("Expected the mate in 1, got", nxt)
+	}
+}
+
 // func TestChessProblemsMateInThree(t *testing.T) {
 //	game := NewBoard()
 //	// Mate in Three
@@ -345,6 +444,36 @@ func BenchmarkMidGamePruningDepth5(b *testing.B) {
 	}
 }
 
+func BenchmarkMidGameOptionsDepth4(b *testing.B) {
+	game := NewBoard()
+	fen := "r1bqkb1r/1p3ppp/p1n2n2/3p4/8/1N1B4/PPP2PPP/RNBQ1RK1 w kq - 0 9"
+	_ = game.LoadFen(fen)
+	s := GetState(&game)
+	s.SetOptions(DefaultOptions())
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		_, err := MiniMaxPruning(0, 4, s)
+		if err != nil {
+			fmt.Println(err)
+		}
+	}
+}
+
+func BenchmarkMidGameOptionsDepth5(b *testing.B) {
+	game := NewBoard()
+	fen := "r1bqkb1r/1p3ppp/p1n2n2/3p4/8/1N1B4/PPP2PPP/RNBQ1RK1 w kq - 0 9"
+	_ = game.LoadFen(fen)
+	s := GetState(&game)
+	s.SetOptions(DefaultOptions())
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		_, err := MiniMaxPruning(0, 5, s)
+		if err != nil {
+			fmt.Println(err)
+		}
+	}
+}
+
 func BenchmarkMidGamePruningDepth5v2(b *testing.B) {
 	// Seems to be about four seconds
 	game := NewBoard()
@@ -0,0 +1,157 @@
+// Package ghess is a chess engine. This file concerns the
+// evaluation of piece activity: mobility, rooks on open
+// files, outposts and the bishop pair.
+package ghess
+
+// onBoard returns true if idx is one of the 64 squares.
+func onBoard(idx int) bool {
+	return idx > 10 && idx < 89 && idx%10 != 0 && idx%10 != 9
+}
+
+// mobility counts the squares the piece on idx can move to,
+// empty or enemy, ignoring pins.
+func (b *Board) mobility(idx int) int {
+	isWhite := b.isUpper(idx)
+	var count int
+	// target counts a square, and returns false if
+	// a sliding piece has to stop there.
+	target := func(sq int) bool {
+		if !onBoard(sq) {
+			return false
+		}
+		if b.board[sq] == '.' {
+			count++
+			return true
+		}
+		if b.isUpper(sq) != isWhite {
+			count++
+		}
+		return false
+	}
+	switch b.board[idx] {
+	case 'N', 'n':
+		for _, move := range knightMoves {
+			target(idx + move)
+		}
+	case 'B', 'b':
+		for _, move := range bishopMoves {
+			for sq := idx + move; target(sq); sq += move {
+			}
+		}
+	case 'R', 'r':
+		for _, move := range rookMoves {
+			for sq := idx + move; target(sq); sq += move {
+			}
+		}
+	case 'Q', 'q':
+		for _, move := range royalMoves {
+			for sq := idx + move; target(sq); sq += move {
+			}
+		}
+	}
+	return count
+}
+
+// evaluatePieces returns the Middle Game and End Game scores
+// of mobility, and of the other piece terms, for White and Black.
+func (b *Board) evaluatePieces(ep *EvalParams) ([2]phaseScore, [2]phaseScore) {
+	var mobility, pieces [2]phaseScore
+	var bishops [2]int
+	for idx := 11; idx < 89; idx++ {
+		val := b.board[idx]
+		if val == '.' || val == ' ' {
+			continue
+		}
+		isWhite := b.isUpper(idx)
+		side := 0
+		if !isWhite {
+			side = 1
+		}
+		p := ByteToUpper[val]
+		switch p {
+		case 'N', 'B', 'R', 'Q':
+			moves := b.mobility(idx) - ep.mobileBase[p]
+			mobility[side].add(ep.mobility[p][0]*moves,
+				ep.mobility[p][1]*moves)
+		}
+		switch p {
+		case 'R':
+			switch b.fileOpen(idx, isWhite) {
+			case 2:
+				pieces[side].add(ep.RookOpenFile[0], ep.RookOpenFile[1])
+			case 1:
+				pieces[side].add(ep.RookHalfOpenFile[0], ep.RookHalfOpenFile[1])
+			}
+			if isWhite && idx/10 == 7 || !isWhite && idx/10 == 2 {
+				pieces[side].add(ep.RookSeventh[0], ep.RookSeventh[1])
+			}
+		case 'N':
+			if b.outpost(idx, isWhite) {
+				pieces[side].add(ep.KnightOutpost[0], ep.KnightOutpost[1])
+			}
+		case 'B':
+			bishops[side]++
+			if b.outpost(idx, isWhite) {
+				pieces[side].add(ep.BishopOutpost[0], ep.BishopOutpost[1])
+			}
+		}
+	}
+	for side := 0; side < 2; side++ {
+		if bishops[side] > 1 {
+			pieces[side].add(ep.BishopPair[0], ep.BishopPair[1])
+		}
+	}
+	return mobility, pieces
+}
+
+// fileOpen returns 2 if there are no pawns on the file of idx,
+// 1 if there are only enemy pawns, and 0 otherwise.
+func (b *Board) fileOpen(idx int, isWhite bool) int {
+	own, enemy := byte('P'), byte('p')
+	if !isWhite {
+		own, enemy = 'p', 'P'
+	}
+	open := 2
+	for sq := 20 + idx%10; sq < 80; sq += 10 {
+		switch b.board[sq] {
+		case own:
+			return 0
+		case enemy:
+			open = 1
+		}
+	}
+	return open
+}
+
+// outpost returns true if the piece on idx is on the 4th to
+// 6th rank, from its own side, protected by a pawn, and no
+// enemy pawn can ever attack it.
+func (b *Board) outpost(idx int, isWhite bool) bool {
+	rank := idx / 10
+	if !isWhite {
+		rank = 9 - rank
+	}
+	if rank < 4 || rank > 6 {
+		return false
+	}
+	if !b.pawnProtect(idx, isWhite) {
+		return false
+	}
+	// Enemy pawns on the adjacent files, in front
+	for _, file := range [2]int{idx - 1, idx + 1} {
+		if isWhite {
+			for sq := file + 10; sq < 89; sq += 10 {
+				if b.board[sq] == 'p' {
+					return false
+				}
+			}
+		} else {
+			for sq := file - 10; sq > 10; sq -= 10 {
+				if b.board[sq] == 'P' {
+					return false
+				}
+			}
+		}
+	}
+	return true
+}
@@ -0,0 +1,68 @@
+package ghess
+
+import (
+	"testing"
+)
+
+func TestMobility(t *testing.T) {
+	game := NewBoard()
+	_ = game.LoadFen(`4k3/8/8/8/3N4/8/8/R3K3 w - - 0 30`)
+	if n := game.mobility(45); n != 8 {
+		t.Error("Knight on d4 has 8 moves, got", n)
+	}
+	// a1 Rook, blocked by its King on e1
+	if n := game.mobility(18); n != 10 {
+		t.Error("Rook on a1 has 10 moves, got", n)
+	}
+	game = NewBoard()
+	if n := game.mobility(17); n != 2 {
+		t.Error("Knight on b1 has 2 moves, got", n)
+	}
+	if n := game.mobility(15); n != 0 {
+		t.Error("Queen on d1 has no moves, got", n)
+	}
+}
+
+func TestRookFiles(t *testing.T) {
+	game := NewBoard()
+	_ = game.LoadFen(`4k3/1p6/8/8/8/8/6P1/R1R1K1R1 w - - 0 30`)
+	if game.fileOpen(18, true) != 2 {
+		t.Error("a file is open")
+	}
+	if game.fileOpen(16, true) != 2 {
+		t.Error("c file is open")
+	}
+	if game.fileOpen(12, true) != 0 {
+		t.Error("g file is closed")
+	}
+	_ = game.LoadFen(`4k3/1p6/8/8/8/8/8/1R2K3 w - - 0 30`)
+	if game.fileOpen(17, true) != 1 {
+		t.Error("b file is half open")
+	}
+}
+
+func TestOutpost(t *testing.T) {
+	game := NewBoard()
+	// Knight on d5 protected by e4, no Black c or e pawns
+	_ = game.LoadFen(`4k3/pp4pp/8/3N4/4P3/8/8/4K3 w - - 0 30`)
+	if !game.outpost(55, true) {
+		t.Error("d5 is an outpost")
+	}
+	_ = game.LoadFen(`4k3/pp2p1pp/8/3N4/4P3/8/8/4K3 w - - 0 30`)
+	if game.outpost(55, true) {
+		t.Error("e7 can chase the Knight from d5")
+	}
+}
+
+func TestBishopPair(t *testing.T) {
+	game := NewBoard()
+	_ = game.LoadFen(`4k3/8/8/8/8/8/8/2B1KB2 w - - 0 30`)
+	_, pieces := game.evaluatePieces(defaultParams)
+	pair := pieces[0][0]
+	_ = game.LoadFen(`4k3/8/8/8/8/8/8/2B1KN2 w - - 0 30`)
+	_, pieces = game.evaluatePieces(defaultParams)
+	knight := pieces[0][0]
+	if pair-knight < defaultParams.BishopPair[0] {
+		t.Error("Bishop pair should be worth more", pair, knight)
+	}
+}
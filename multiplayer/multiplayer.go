@@ -0,0 +1,463 @@
+// Package multiplayer is a websocket server of ghess games
+// between two players, watched by any number of spectators.
+// Each room is a game, joined by its name:
+//
+//	ws://host/rooms/{name}?seat=white   (or black, or watch)
+//
+// Without a seat the first free one is taken, or else the game
+// is watched. A seat left is free again, but a player leaving a
+// game in progress abandons it, and loses. Players send
+// {"type": "move", "move": "e4"} with the move in SAN or
+// coordinates, or {"type": "resign"}.
+//
+// Clients receive Events: the state of the room on joining,
+// then joined, left, move, check, checkmate, stalemate, draw,
+// resign, abandon, timeout and clock. A move out of turn, or from a
+// spectator, is answered with an error to its sender alone.
+package multiplayer
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/polypmer/ghess"
+)
+
+const writeWait = 10 * time.Second // to write an event
+
+// Options are the time controls of the games.
+type Options struct {
+	Clock     time.Duration // of each player, 0 for none
+	Increment time.Duration // added after each move
+	Tick      time.Duration // between clock events, 0 for a second
+}
+
+// Hub is the rooms of a server, see the package comment.
+type Hub struct {
+	opts     Options
+	upgrader websocket.Upgrader
+	mu       sync.Mutex
+	rooms    map[string]*room
+}
+
+// Message is what players send.
+type Message struct {
+	Type string `json:"type"` // move or resign
+	Move string `json:"move,omitempty"`
+}
+
+// Event is what clients receive.
+type Event struct {
+	Type   string   `json:"type"`
+	Seat   string   `json:"seat,omitempty"` // the client's on state, else who it concerns
+	Move   string   `json:"move,omitempty"` // SAN
+	UCI    string   `json:"uci,omitempty"`
+	FEN    string   `json:"fen,omitempty"`
+	Moves  []string `json:"moves,omitempty"` // played, on state
+	Result string   `json:"result,omitempty"`
+	Clock  *Clock   `json:"clock,omitempty"`
+	Error  string   `json:"error,omitempty"`
+}
+
+// Clock is the time left to each player, in milliseconds.
+type Clock struct {
+	White int64 `json:"white"`
+	Black int64 `json:"black"`
+}
+
+// room is a game and the clients watching it.
+type room struct {
+	hub      *Hub
+	mu       sync.Mutex
+	board    ghess.Board
+	moves    []string         // in SAN
+	players  [2]*client       // White then Black
+	watchers map[*client]bool // spectators
+	clock    [2]time.Duration // left, when the turn began
+	turn     time.Time        // when the turn began
+	started  bool             // once both players joined
+	over     bool
+	result   string
+}
+
+// client is a websocket connection to a room.
+type client struct {
+	conn *websocket.Conn
+	seat string // white, black or watch
+	send chan Event
+}
+
+// NewHub returns a Hub without rooms.
+func NewHub(opts Options) *Hub {
+	if opts.Tick <= 0 {
+		opts.Tick = time.Second
+	}
+	return &Hub{opts: opts, rooms: make(map[string]*room)}
+}
+
+// ServeHTTP joins the room named by the last element of the
+// path, upgrading to a websocket.
+func (h *Hub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	name := path.Base(r.URL.Path)
+	if name == "/" || name == "." {
+		http.Error(w, "No room", http.StatusNotFound)
+		return
+	}
+	seat := r.URL.Query().Get("seat")
+	if seat != "" && seat != "white" && seat != "black" && seat != "watch" {
+		http.Error(w, "seat is white, black or watch", http.StatusBadRequest)
+		return
+	}
+	c := &client{send: make(chan Event, 64)}
+	rm, err := h.take(name, c, seat)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	conn, err := h.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		rm.leave(c) // Upgrade answered already
+		return
+	}
+	// Events may already be posted to the client
+	rm.mu.Lock()
+	c.conn = conn
+	rm.mu.Unlock()
+	go c.write()
+	rm.join(c)
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			break
+		}
+		var m Message
+		if err := json.Unmarshal(data, &m); err != nil {
+			rm.mu.Lock()
+			c.post(Event{Type: "error", Error: "Invalid JSON: " + err.Error()})
+			rm.mu.Unlock()
+			continue
+		}
+		rm.handle(c, m)
+	}
+	rm.leave(c)
+}
+
+// take seats the client in the room, making the room if new.
+func (h *Hub) take(name string, c *client, seat string) (*room, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	rm, ok := h.rooms[name]
+	if !ok {
+		rm = &room{hub: h, board: ghess.NewBoard(), watchers: make(map[*client]bool)}
+		rm.clock = [2]time.Duration{h.opts.Clock, h.opts.Clock}
+		h.rooms[name] = rm
+	}
+	rm.mu.Lock()
+	defer rm.mu.Unlock()
+	switch {
+	case seat == "white" || seat == "black":
+		if rm.players[index(seat)] != nil {
+			return nil, errors.New("The " + seat + " seat is taken")
+		}
+	case seat == "" && rm.players[0] == nil:
+		seat = "white"
+	case seat == "" && rm.players[1] == nil:
+		seat = "black"
+	default:
+		seat = "watch"
+	}
+	c.seat = seat
+	if seat == "watch" {
+		rm.watchers[c] = true
+	} else {
+		rm.players[index(seat)] = c
+	}
+	return rm, nil
+}
+
+// index is 0 for white and 1 for black.
+func index(seat string) int {
+	if seat == "black" {
+		return 1
+	}
+	return 0
+}
+
+// write sends the client's events until its channel is closed.
+func (c *client) write() {
+	defer c.conn.Close()
+	for e := range c.send {
+		_ = c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+		if err := c.conn.WriteJSON(e); err != nil {
+			return
+		}
+	}
+	_ = c.conn.WriteControl(websocket.CloseMessage,
+		websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), time.Now().Add(writeWait))
+}
+
+// post queues an event for the client, disconnecting it if
+// it can't keep up. The room must be locked.
+func (c *client) post(e Event) {
+	select {
+	case c.send <- e:
+	default:
+		if c.conn != nil {
+			c.conn.Close()
+		}
+	}
+}
+
+// broadcast posts an event to every client of the room,
+// which must be locked.
+func (r *room) broadcast(e Event) {
+	for _, c := range r.players {
+		if c != nil {
+			c.post(e)
+		}
+	}
+	for c := range r.watchers {
+		c.post(e)
+	}
+}
+
+// join sends the state to the client and tells the others,
+// starting the game once both players are in.
+func (r *room) join(c *client) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.started && r.players[0] != nil && r.players[1] != nil {
+		r.started, r.turn = true, time.Now()
+		if r.hub.opts.Clock > 0 {
+			go r.tick()
+		}
+	}
+	c.post(Event{
+		Type:   "state",
+		Seat:   c.seat,
+		FEN:    r.board.Position(),
+		Moves:  append([]string{}, r.moves...),
+		Result: r.result,
+		Clock:  r.clocks(),
+	})
+	for _, other := range r.players {
+		if other != nil && other != c {
+			other.post(Event{Type: "joined", Seat: c.seat})
+		}
+	}
+	for other := range r.watchers {
+		if other != c {
+			other.post(Event{Type: "joined", Seat: c.seat})
+		}
+	}
+}
+
+// leave removes the client, and the room once empty. A
+// player leaving a game in progress loses it.
+func (r *room) leave(c *client) {
+	r.hub.mu.Lock()
+	defer r.hub.mu.Unlock()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if c.seat == "watch" {
+		delete(r.watchers, c)
+	} else {
+		r.players[index(c.seat)] = nil
+	}
+	close(c.send)
+	r.broadcast(Event{Type: "left", Seat: c.seat})
+	if c.seat != "watch" && r.started && !r.over {
+		result := "0-1"
+		if c.seat == "black" {
+			result = "1-0"
+		}
+		r.end("abandon", c.seat, result)
+	}
+	if r.empty() {
+		for name, rm := range r.hub.rooms {
+			if rm == r {
+				delete(r.hub.rooms, name)
+			}
+		}
+	}
+}
+
+// empty is whether no client is left.
+func (r *room) empty() bool {
+	return r.players[0] == nil && r.players[1] == nil && len(r.watchers) == 0
+}
+
+// handle answers a message from the client.
+func (r *room) handle(c *client, m Message) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var err error
+	switch m.Type {
+	case "move":
+		err = r.move(c, m.Move)
+	case "resign":
+		err = r.resign(c)
+	default:
+		err = errors.New("Unknown message type " + m.Type)
+	}
+	if err != nil {
+		c.post(Event{Type: "error", Error: err.Error()})
+	}
+}
+
+// side returns white or black, the player to move.
+func (r *room) side() string {
+	if strings.Fields(r.board.Position())[1] == "b" {
+		return "black"
+	}
+	return "white"
+}
+
+// playing returns an error unless the client can play now.
+func (r *room) playing(c *client) error {
+	switch {
+	case r.over:
+		return errors.New("The game is over")
+	case c.seat == "watch":
+		return errors.New("Spectators can't play")
+	case !r.started:
+		return errors.New("Waiting for an opponent")
+	}
+	return nil
+}
+
+// move plays the client's move, in SAN or coordinates.
+func (r *room) move(c *client, move string) error {
+	if err := r.playing(c); err != nil {
+		return err
+	}
+	side := r.side()
+	if c.seat != side {
+		return errors.New("Not your move")
+	}
+	if r.flag() {
+		return nil // too late
+	}
+	orig, dest, err := resolve(&r.board, move)
+	if err != nil {
+		return errors.New("Illegal move " + move + ": " + err.Error())
+	}
+	uci := r.board.UCIMove(orig, dest)
+	san := r.board.SAN(orig, dest)
+	if err := r.board.Move(orig, dest); err != nil {
+		return errors.New("Illegal move " + move + ": " + err.Error())
+	}
+	r.moves = append(r.moves, san)
+	if r.hub.opts.Clock > 0 {
+		i := index(side)
+		r.clock[i] += r.hub.opts.Increment - time.Since(r.turn)
+	}
+	r.turn = time.Now()
+	r.broadcast(Event{Type: "move", Seat: side, Move: san, UCI: uci,
+		FEN: r.board.Position(), Clock: r.clocks()})
+
+	opponent := "black"
+	if side == "black" {
+		opponent = "white"
+	}
+	switch {
+	case r.board.Checkmate:
+		r.end("checkmate", side, r.board.Score)
+	case r.board.Draw:
+		r.end("draw", "", "1/2-1/2")
+	case r.board.GameOver():
+		r.end("stalemate", opponent, "1/2-1/2")
+	case r.board.Check:
+		r.broadcast(Event{Type: "check", Seat: opponent})
+	}
+	return nil
+}
+
+// resolve returns the coordinates of a move in either notation.
+func resolve(b *ghess.Board, move string) (int, int, error) {
+	move = strings.TrimSpace(move)
+	if orig, dest, err := b.ParseUCI(move); err == nil {
+		return orig, dest, nil
+	}
+	return b.ParseSAN(move)
+}
+
+// resign ends the game, lost by the client.
+func (r *room) resign(c *client) error {
+	if err := r.playing(c); err != nil {
+		return err
+	}
+	result := "0-1"
+	if c.seat == "black" {
+		result = "1-0"
+	}
+	r.end("resign", c.seat, result)
+	return nil
+}
+
+// end ends the game, telling everyone, and stops the clocks.
+func (r *room) end(event, seat, result string) {
+	if r.hub.opts.Clock > 0 && r.started {
+		r.clock[index(r.side())] -= time.Since(r.turn)
+	}
+	r.over, r.result = true, result
+	r.broadcast(Event{Type: event, Seat: seat, Result: result, Clock: r.clocks()})
+}
+
+// clocks returns the time left, nil without clocks.
+func (r *room) clocks() *Clock {
+	if r.hub.opts.Clock <= 0 {
+		return nil
+	}
+	left := r.clock
+	if r.started && !r.over {
+		left[index(r.side())] -= time.Since(r.turn)
+	}
+	for i := range left {
+		if left[i] < 0 {
+			left[i] = 0
+		}
+	}
+	return &Clock{White: left[0].Milliseconds(), Black: left[1].Milliseconds()}
+}
+
+// flag ends the game if the player to move is out of time.
+func (r *room) flag() bool {
+	if r.hub.opts.Clock <= 0 || !r.started || r.over {
+		return false
+	}
+	side := r.side()
+	if r.clock[index(side)] > time.Since(r.turn) {
+		return false
+	}
+	result := "0-1"
+	if side == "black" {
+		result = "1-0"
+	}
+	r.end("timeout", side, result)
+	return true
+}
+
+// tick sends the clocks every Options.Tick, and ends the
+// game when a player runs out of time.
+func (r *room) tick() {
+	ticker := time.NewTicker(r.hub.opts.Tick)
+	defer ticker.Stop()
+	for range ticker.C {
+		r.mu.Lock()
+		if r.over || r.empty() {
+			r.mu.Unlock()
+			return
+		}
+		if !r.flag() {
+			r.broadcast(Event{Type: "clock", Clock: r.clocks()})
+		}
+		r.mu.Unlock()
+	}
+}
@@ -0,0 +1,154 @@
+package multiplayer
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// dial joins a room of the server.
+func dial(t *testing.T, srv *httptest.Server, query string) *websocket.Conn {
+	t.Helper()
+	url := "ws" + strings.TrimPrefix(srv.URL, "http") + "/rooms/test" + query
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return conn
+}
+
+// expect reads events until one of the type, skipping
+// the clocks and who joined.
+func expect(t *testing.T, conn *websocket.Conn, typ string) Event {
+	t.Helper()
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for {
+		var e Event
+		if err := conn.ReadJSON(&e); err != nil {
+			t.Fatal("Waiting for", typ, err)
+		}
+		if e.Type == typ {
+			return e
+		}
+		if e.Type != "clock" && e.Type != "joined" {
+			t.Fatal("Expected", typ, "not", e)
+		}
+	}
+}
+
+// send sends a move from a client.
+func send(t *testing.T, conn *websocket.Conn, move string) {
+	t.Helper()
+	if err := conn.WriteJSON(Message{Type: "move", Move: move}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRoom(t *testing.T) {
+	srv := httptest.NewServer(NewHub(Options{}))
+	defer srv.Close()
+	white := dial(t, srv, "")
+	defer white.Close()
+	if e := expect(t, white, "state"); e.Seat != "white" || !strings.HasPrefix(e.FEN, "rnbqkbnr/") {
+		t.Fatal("First should be White", e)
+	}
+	send(t, white, "e4")
+	if e := expect(t, white, "error"); e.Error != "Waiting for an opponent" {
+		t.Error("Should wait for Black", e)
+	}
+	black := dial(t, srv, "?seat=black")
+	defer black.Close()
+	expect(t, black, "state")
+	watcher := dial(t, srv, "")
+	defer watcher.Close()
+	if e := expect(t, watcher, "state"); e.Seat != "watch" {
+		t.Error("Third should watch", e)
+	}
+	url := "ws" + strings.TrimPrefix(srv.URL, "http") + "/rooms/test?seat=white"
+	if _, resp, err := websocket.DefaultDialer.Dial(url, nil); err == nil || resp.StatusCode != http.StatusConflict {
+		t.Error("White seat should be taken", err)
+	}
+
+	send(t, black, "e5")
+	if e := expect(t, black, "error"); e.Error != "Not your move" {
+		t.Error("Black moved out of turn", e)
+	}
+	send(t, watcher, "f3")
+	if e := expect(t, watcher, "error"); e.Error != "Spectators can't play" {
+		t.Error("Spectator moved", e)
+	}
+
+	// Fool's mate, in both notations
+	for i, move := range []string{"f3", "e7e5", "g4", "Qh4#"} {
+		player := white
+		if i%2 == 1 {
+			player = black
+		}
+		send(t, player, move)
+		for _, conn := range []*websocket.Conn{white, black, watcher} {
+			if e := expect(t, conn, "move"); e.Move != []string{"f3", "e5", "g4", "Qh4#"}[i] {
+				t.Error("Wrong move", e)
+			}
+		}
+	}
+	for _, conn := range []*websocket.Conn{white, black, watcher} {
+		if e := expect(t, conn, "checkmate"); e.Result != "0-1" || e.Seat != "black" {
+			t.Error("Black should mate", e)
+		}
+	}
+	send(t, white, "d4")
+	if e := expect(t, white, "error"); e.Error != "The game is over" {
+		t.Error("Moved after mate", e)
+	}
+}
+
+func TestTimeout(t *testing.T) {
+	srv := httptest.NewServer(NewHub(Options{Clock: 200 * time.Millisecond, Tick: 20 * time.Millisecond}))
+	defer srv.Close()
+	white := dial(t, srv, "?seat=white")
+	defer white.Close()
+	expect(t, white, "state")
+	black := dial(t, srv, "?seat=black")
+	defer black.Close()
+	if e := expect(t, black, "state"); e.Clock == nil || e.Clock.Black != 200 {
+		t.Fatal("Black should have the whole clock", e)
+	}
+	send(t, white, "d4")
+	if e := expect(t, black, "move"); e.Clock == nil || e.Clock.White > 200 {
+		t.Error("White's clock should have run", e)
+	}
+	expect(t, white, "move")
+	e := expect(t, white, "timeout")
+	if e.Seat != "black" || e.Result != "1-0" || e.Clock.Black != 0 {
+		t.Error("Black should lose on time", e)
+	}
+}
+
+func TestAbandon(t *testing.T) {
+	srv := httptest.NewServer(NewHub(Options{Clock: time.Minute}))
+	defer srv.Close()
+	white := dial(t, srv, "")
+	defer white.Close()
+	expect(t, white, "state")
+	black := dial(t, srv, "")
+	expect(t, black, "state")
+	send(t, white, "e4")
+	expect(t, black, "move")
+	expect(t, white, "move")
+	black.Close()
+	if e := expect(t, white, "left"); e.Seat != "black" {
+		t.Error("Black should leave", e)
+	}
+	e := expect(t, white, "abandon")
+	if e.Seat != "black" || e.Result != "1-0" || e.Clock == nil {
+		t.Error("Black should lose by leaving", e)
+	}
+	send(t, white, "d4")
+	if e := expect(t, white, "error"); e.Error != "The game is over" {
+		t.Error("Moved after abandon", e)
+	}
+}
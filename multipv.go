@@ -0,0 +1,95 @@
+// Package ghess is a chess engine. This file concerns the
+// multi-PV analysis of a root position.
+package ghess
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Line is one candidate move at the root, with its score
+// and the principal variation which follows from it.
+type Line struct {
+	Move  [2]int   // origin and destination
+	Score int      // positive for White advantage
+	PV    [][2]int // the variation, starting with Move
+}
+
+// Mate returns the moves to mate in this Line, positive if
+// White mates, negative if Black mates, or 0.
+func (l Line) Mate() int {
+	return mateIn(l.Score)
+}
+
+// String returns the score and the variation
+// in coordinate notation, eg. "35 e2e4 e7e5" or
+// "mate 1 g2g1".
+func (l Line) String() string {
+	moves := make([]string, 0, len(l.PV))
+	for _, move := range l.PV {
+		moves = append(moves, PieceMap[move[0]]+PieceMap[move[1]])
+	}
+	score := strconv.Itoa(l.Score)
+	if mate := l.Mate(); mate != 0 {
+		score = "mate " + strconv.Itoa(mate)
+	}
+	return score + " " + strings.Join(moves, " ")
+}
+
+// MultiPV searches every root move of s to terminal depth
+// and returns the best n Lines, best first for the player
+// to move. Moves are searched with a window around the n'th
+// best score so far, so only the top n scores are exact.
+// The opening dictionary is not consulted.
+func MultiPV(terminal, n int, s State) ([]Line, error) {
+	s.alpha = -MateScore
+	s.beta = MateScore
+	s.isMax = s.board.toMove == "w"
+	maxNode := s.isMax
+
+	states, err := GetPossibleStates(s)
+	if err != nil {
+		return nil, err
+	}
+	orderStates(states, maxNode)
+
+	lines := make([]Line, 0, len(states))
+	for _, state := range states {
+		state.alpha, state.beta = s.alpha, s.beta
+		if len(lines) >= n && n > 0 {
+			// Only moves better than the n'th need exact scores
+			if maxNode {
+				state.alpha = lines[n-1].Score
+			} else {
+				state.beta = lines[n-1].Score
+			}
+		}
+		bestState := state
+		if terminal > 1 {
+			bestState, err = MiniMaxPruning(1, terminal, state)
+			if err != nil {
+				return nil, err
+			}
+		}
+		pv := bestState.PV()
+		if len(pv) < 1 {
+			pv = [][2]int{state.move}
+		}
+		lines = append(lines, Line{
+			Move:  state.move,
+			Score: bestState.eval,
+			PV:    pv,
+		})
+		sort.SliceStable(lines, func(i, j int) bool {
+			if maxNode {
+				return lines[i].Score > lines[j].Score
+			}
+			return lines[i].Score < lines[j].Score
+		})
+	}
+	if n > 0 && len(lines) > n {
+		lines = lines[:n]
+	}
+	return lines, nil
+}
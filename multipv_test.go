@@ -0,0 +1,58 @@
+package ghess
+
+import (
+	"testing"
+)
+
+func TestMultiPV(t *testing.T) {
+	game := NewBoard()
+	fen := `4k3/8/8/8/8/7r/6r1/1K6 b - - 0 2`
+	_ = game.LoadFen(fen)
+	lines, err := MultiPV(2, 3, GetState(&game))
+	if err != nil {
+		t.Error(err)
+	}
+	if len(lines) != 3 {
+		t.Fatal("Expected three lines, got", len(lines))
+	}
+	for i := 1; i < len(lines); i++ {
+		if lines[i].Score < lines[i-1].Score {
+			t.Error("Lines are not ordered for Black", lines)
+		}
+	}
+	// The first line is the mate
+	err = game.Move(lines[0].Move[0], lines[0].Move[1])
+	if err != nil {
+		t.Error(err)
+	}
+	if !game.Checkmate {
+		t.Error("First line isn't Checkmate", lines[0])
+	}
+	if lines[0].PV[0] != lines[0].Move {
+		t.Error("Variation doesn't begin with the move", lines[0])
+	}
+}
+
+func TestStatePV(t *testing.T) {
+	game := NewBoard()
+	fen := "r1bqkb1r/1p3ppp/p1n2n2/3p4/8/1N1B4/PPP2PPP/RNBQ1RK1 w kq - 0 9"
+	_ = game.LoadFen(fen)
+	s, err := MiniMaxPruning(0, 3, GetState(&game))
+	if err != nil {
+		t.Error(err)
+	}
+	pv := s.PV()
+	if len(pv) != 3 {
+		t.Fatal("Expected three plies, got", pv)
+	}
+	if pv[0] != s.Init {
+		t.Error("Variation doesn't begin with Init", pv, s.Init)
+	}
+	// Every move in the variation is legal
+	for _, move := range pv {
+		err = game.Move(move[0], move[1])
+		if err != nil {
+			t.Error(err, move)
+		}
+	}
+}
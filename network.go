@@ -0,0 +1,235 @@
+// Package ghess is a chess engine. This file concerns a small
+// feed-forward neural network evaluation, read from a weights
+// file, for trying learned evaluations against EvalParams.
+//
+// The input is one-hot, one neuron for each piece on each
+// square, followed by one or two hidden layers of ReLU neurons
+// and an output neuron which is the score in centipawns for
+// White. It all runs in pure Go on the CPU, the first layer
+// only adding up the weights of the pieces on the board.
+package ghess
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+)
+
+// networkPieces orders the input planes, White first.
+const networkPieces = "PNBRQKpnbrqk"
+
+// networkInputs is a neuron for each piece on each square.
+const networkInputs = 12 * 64
+
+// networkMagic starts a weights file.
+var networkMagic = [4]byte{'G', 'H', 'N', 'N'}
+
+// maxNetworkSize is the largest hidden layer, keeping a
+// network with two of them under 8MB of weights.
+const maxNetworkSize = 1 << 10
+
+// ErrNetworkFormat is returned reading a file which isn't
+// a network weights file.
+var ErrNetworkFormat = errors.New("Not a network weights file")
+
+// Network is a feed-forward network, it implements Evaluator.
+//
+// The weights file is little-endian: the bytes "GHNN", then
+// uint32 the number of hidden layers, 1 or 2, and uint32 the
+// size of a hidden layer. Each layer follows, the hidden layers
+// then the output, as float32 weights by input then by neuron,
+// and float32 biases by neuron. The inputs are the pieces in
+// the order PNBRQKpnbrqk, each over the squares a1 b1 ... h8.
+type Network struct {
+	layers []networkLayer
+}
+
+// networkLayer is a fully connected layer.
+type networkLayer struct {
+	in, out int
+	weights []float32 // weights[i*out+j] joins input i to neuron j
+	biases  []float32
+}
+
+// NewNetwork returns a Network with zeroed weights, and
+// 1 or 2 hidden layers of size neurons.
+func NewNetwork(hidden, size int) (*Network, error) {
+	if hidden < 1 || hidden > 2 {
+		return nil, fmt.Errorf("Networks have 1 or 2 hidden layers, not %d", hidden)
+	}
+	if size < 1 || size > maxNetworkSize {
+		return nil, fmt.Errorf("Hidden layer size %d out of range", size)
+	}
+	n := &Network{}
+	in := networkInputs
+	for l := 0; l <= hidden; l++ {
+		out := size
+		if l == hidden {
+			out = 1
+		}
+		n.layers = append(n.layers, networkLayer{
+			in:      in,
+			out:     out,
+			weights: make([]float32, in*out),
+			biases:  make([]float32, out),
+		})
+		in = out
+	}
+	return n, nil
+}
+
+// networkInput returns the input neuron of a piece on
+// a Board coordinate, or -1 for an empty square.
+func networkInput(piece byte, idx int) int {
+	for i := 0; i < len(networkPieces); i++ {
+		if networkPieces[i] == piece {
+			rank, file := idx/10-1, 8-idx%10
+			return i*64 + rank*8 + file
+		}
+	}
+	return -1
+}
+
+// Evaluate scores the board, see Board.Evaluate. Mates are
+// scored as the hand written evaluation does, and the network's
+// score is kept below tablebase wins. A draw adds the default
+// EvalParams' Draw to the score, as the hand written one does.
+func (n *Network) Evaluate(b *Board) int {
+	if b.Checkmate {
+		if b.Score == "0-1" {
+			return -MateScore
+		} else if b.Score == "1-0" {
+			return MateScore
+		}
+	}
+	first := n.layers[0]
+	hidden := make([]float32, first.out)
+	copy(hidden, first.biases)
+	for idx, val := range b.board {
+		if val == '.' || val == ' ' {
+			continue
+		}
+		in := networkInput(val, idx)
+		if in < 0 {
+			continue
+		}
+		row := first.weights[in*first.out : (in+1)*first.out]
+		for j, w := range row {
+			hidden[j] += w
+		}
+	}
+	relu(hidden)
+	for l, layer := range n.layers[1:] {
+		next := make([]float32, layer.out)
+		copy(next, layer.biases)
+		for i, x := range hidden {
+			if x == 0 {
+				continue
+			}
+			row := layer.weights[i*layer.out : (i+1)*layer.out]
+			for j, w := range row {
+				next[j] += x * w
+			}
+		}
+		if l < len(n.layers)-2 { // no ReLU on the output
+			relu(next)
+		}
+		hidden = next
+	}
+
+	score := int(math.Round(float64(hidden[0])))
+	if score >= tbWin {
+		score = tbWin - 1
+	} else if score <= -tbWin {
+		score = -tbWin + 1
+	}
+	if b.Draw {
+		score += defaultParams.Draw
+	}
+	return score
+}
+
+// relu zeroes the negative values.
+func relu(xs []float32) {
+	for i, x := range xs {
+		if x < 0 {
+			xs[i] = 0
+		}
+	}
+}
+
+// ReadNetwork reads a network weights file, see Network.
+func ReadNetwork(r io.Reader) (*Network, error) {
+	r = bufio.NewReader(r)
+	var header struct {
+		Magic  [4]byte
+		Layers uint32
+		Size   uint32
+	}
+	if err := binary.Read(r, binary.LittleEndian, &header); err != nil {
+		return nil, ErrNetworkFormat
+	}
+	if header.Magic != networkMagic {
+		return nil, ErrNetworkFormat
+	}
+	// The header isn't trusted with the allocation
+	if header.Layers < 1 || header.Layers > 2 || header.Size < 1 || header.Size > maxNetworkSize {
+		return nil, ErrNetworkFormat
+	}
+	n, err := NewNetwork(int(header.Layers), int(header.Size))
+	if err != nil {
+		return nil, err
+	}
+	for _, layer := range n.layers {
+		if err := binary.Read(r, binary.LittleEndian, layer.weights); err != nil {
+			return nil, fmt.Errorf("Reading network weights: %v", err)
+		}
+		if err := binary.Read(r, binary.LittleEndian, layer.biases); err != nil {
+			return nil, fmt.Errorf("Reading network biases: %v", err)
+		}
+	}
+	return n, nil
+}
+
+// LoadNetwork reads a network weights file from path.
+func LoadNetwork(path string) (*Network, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ReadNetwork(f)
+}
+
+// Write writes the network as a weights file.
+func (n *Network) Write(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	file := []interface{}{networkMagic, uint32(len(n.layers) - 1), uint32(n.layers[0].out)}
+	for _, layer := range n.layers {
+		file = append(file, layer.weights, layer.biases)
+	}
+	for _, data := range file {
+		if err := binary.Write(bw, binary.LittleEndian, data); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+// Save writes the network to a weights file at path,
+// which LoadNetwork reads back.
+func (n *Network) Save(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := n.Write(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,105 @@
+package ghess
+
+import (
+	"bytes"
+	"testing"
+)
+
+// materialNetwork counts White's material in one hidden
+// neuron and Black's in the other.
+func materialNetwork(t *testing.T, hidden int) *Network {
+	n, err := NewNetwork(hidden, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < len(networkPieces); i++ {
+		piece := networkPieces[i]
+		side := 0
+		if piece > 'Z' {
+			side = 1
+		}
+		for sq := 0; sq < 64; sq++ {
+			n.layers[0].weights[(i*64+sq)*2+side] = float32(pieceValue(piece))
+		}
+	}
+	if hidden == 2 {
+		n.layers[1].weights[0], n.layers[1].weights[3] = 1, 1
+	}
+	n.layers[hidden].weights[0], n.layers[hidden].weights[1] = 1, -1
+	return n
+}
+
+func TestNetworkEvaluate(t *testing.T) {
+	for _, hidden := range []int{1, 2} {
+		n := materialNetwork(t, hidden)
+		game := NewBoard()
+		if eval := n.Evaluate(&game); eval != 0 {
+			t.Error("Start should be even", hidden, eval)
+		}
+		_ = game.LoadFen(`rnbqkb1r/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1`)
+		if eval := n.Evaluate(&game); eval != pieceValue('N') {
+			t.Error("White should be a Knight up", hidden, eval)
+		}
+		eval, hand := n.Evaluate(&game), game.Evaluate()
+		game.Draw = true
+		if n.Evaluate(&game)-eval != game.Evaluate()-hand {
+			t.Error("Draws should score as EvalParams do", hidden, n.Evaluate(&game))
+		}
+	}
+}
+
+func TestNetworkInput(t *testing.T) {
+	if in := networkInput('P', 18); in != 0 {
+		t.Error("a1 White pawn should be the first input", in)
+	}
+	if in := networkInput('k', 81); in != networkInputs-1 {
+		t.Error("h8 Black King should be the last input", in)
+	}
+}
+
+func TestNetworkReadWrite(t *testing.T) {
+	n := materialNetwork(t, 2)
+	var buf bytes.Buffer
+	if err := n.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	read, err := ReadNetwork(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	game := NewBoard()
+	_ = game.LoadFen(`4k3/p7/8/8/8/8/P7/1N2K3 w - - 0 40`)
+	if a, b := n.Evaluate(&game), read.Evaluate(&game); a != b {
+		t.Error("Read network should evaluate the same", a, b)
+	}
+	if _, err := ReadNetwork(bytes.NewReader(buf.Bytes()[:100])); err == nil {
+		t.Error("Short file should be an error")
+	}
+	if _, err := ReadNetwork(bytes.NewReader([]byte("GHNX\x01\x00\x00\x00"))); err != ErrNetworkFormat {
+		t.Error("Wrong magic should be ErrNetworkFormat", err)
+	}
+	huge := []byte("GHNN\x02\x00\x00\x00\xff\xff\xff\xff")
+	if _, err := ReadNetwork(bytes.NewReader(huge)); err != ErrNetworkFormat {
+		t.Error("Huge layers should be ErrNetworkFormat", err)
+	}
+}
+
+func TestSearchEvaluator(t *testing.T) {
+	n := materialNetwork(t, 1)
+	game := NewBoard()
+	s := GetState(&game)
+	s.SetOptions(SearchOptions{Evaluator: n})
+	states, err := GetPossibleStates(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, state := range states {
+		if state.eval != n.Evaluate(state.board) {
+			t.Fatal("Search should evaluate with the network", state.eval)
+		}
+	}
+	var e Evaluator = DefaultParams()
+	if eval := e.Evaluate(&game); eval != game.Evaluate() {
+		t.Error("EvalParams should be an Evaluator", eval)
+	}
+}
@@ -0,0 +1,195 @@
+// Package ghess is a chess engine. This file concerns the
+// parallel search, and the tables shared between goroutines.
+package ghess
+
+import (
+	"sync"
+)
+
+// Principal Variation Search
+
+// pvHash keeps the score of positions searched at the root,
+// pvMap keeps the best move found from a position. Both are
+// read and written by the goroutines of searchParallel.
+var pvHash = &hashTable{m: make(map[[120]byte]int)}
+var pvMap = &moveTable{m: make(map[Board][2]int)}
+
+// pvTableSize is the most entries kept in either table,
+// when full a table is cleared.
+const pvTableSize = 1 << 16
+
+// hashTable is a map of scores safe for concurrent use.
+type hashTable struct {
+	sync.RWMutex
+	m map[[120]byte]int
+}
+
+func (h *hashTable) get(key [120]byte) (int, bool) {
+	h.RLock()
+	defer h.RUnlock()
+	val, ok := h.m[key]
+	return val, ok
+}
+
+func (h *hashTable) put(key [120]byte, val int) {
+	h.Lock()
+	if len(h.m) >= pvTableSize {
+		h.m = make(map[[120]byte]int)
+	}
+	h.m[key] = val
+	h.Unlock()
+}
+
+func (h *hashTable) len() int {
+	h.RLock()
+	defer h.RUnlock()
+	return len(h.m)
+}
+
+// moveTable is a map of best moves safe for concurrent use.
+type moveTable struct {
+	sync.RWMutex
+	m map[Board][2]int
+}
+
+func (t *moveTable) get(b *Board) ([2]int, bool) {
+	t.RLock()
+	defer t.RUnlock()
+	val, ok := t.m[pvKey(b)]
+	return val, ok
+}
+
+func (t *moveTable) put(b *Board, val [2]int) {
+	t.Lock()
+	if len(t.m) >= pvTableSize {
+		t.m = make(map[Board][2]int)
+	}
+	t.m[pvKey(b)] = val
+	t.Unlock()
+}
+
+// pvKey strips the game history from a Board, so the
+// same position reached by other moves finds the same entry.
+func pvKey(b *Board) Board {
+	return Board{
+		board:     b.board,
+		castle:    b.castle,
+		empassant: b.empassant,
+		toMove:    b.toMove,
+	}
+}
+
+// searchParallel splits the root moves of s between
+// SearchOptions.Threads goroutines. Each move is searched
+// by MiniMaxPruning with the best bound found so far, so
+// later moves are still pruned by earlier ones.
+func (s State) searchParallel(terminal int) (State, error) {
+	states, err := GetPossibleStates(s)
+	if err != nil {
+		return s, err
+	}
+	if len(states) < 1 {
+		return s, nil
+	}
+	maxNode := s.isMax
+	s.orderRoot(states, maxNode)
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	var searchErr error
+	bestStates := make(States, len(states))
+	jobs := make(chan int)
+
+	threads := s.opts.Threads
+	if threads > len(states) {
+		threads = len(states)
+	}
+	for i := 0; i < threads; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for idx := range jobs {
+				state := states[idx]
+				mu.Lock()
+				state.alpha, state.beta = s.alpha, s.beta
+				mu.Unlock()
+				bestState, err := MiniMaxPruning(1, terminal, state)
+				mu.Lock()
+				if err != nil {
+					// A stopped search's score is unfinished
+					if searchErr == nil {
+						searchErr = err
+					}
+					mu.Unlock()
+					continue
+				}
+				if maxNode && bestState.eval > s.alpha ||
+					!maxNode && bestState.eval < s.beta {
+					s.opts.report(bestState, false)
+				}
+				if maxNode {
+					s.alpha = max(s.alpha, bestState.eval)
+				} else {
+					s.beta = min(s.beta, bestState.eval)
+				}
+				bestStates[idx] = bestState
+				mu.Unlock()
+				pvHash.put(state.board.board, bestState.eval)
+			}
+		}()
+	}
+	for idx := range states {
+		jobs <- idx
+	}
+	close(jobs)
+	wg.Wait()
+	if searchErr != nil {
+		return s, searchErr
+	}
+
+	var best State
+	if maxNode {
+		best = Max(bestStates)
+	} else {
+		best = Min(bestStates)
+	}
+	pvMap.put(s.board, best.Init)
+	return best, nil
+}
+
+// orderRoot puts the best move from an earlier search first,
+// then the moves by their earlier scores, if there are any.
+func (s State) orderRoot(states States, maxNode bool) {
+	scores := make([]int, len(states))
+	for idx, state := range states {
+		score, ok := pvHash.get(state.board.board)
+		if !ok {
+			score = state.eval
+		}
+		scores[idx] = score
+	}
+	for idx := range states {
+		for j := idx; j > 0; j-- {
+			better := scores[j] > scores[j-1]
+			if !maxNode {
+				better = scores[j] < scores[j-1]
+			}
+			if !better {
+				break
+			}
+			states[j], states[j-1] = states[j-1], states[j]
+			scores[j], scores[j-1] = scores[j-1], scores[j]
+		}
+	}
+	move, ok := pvMap.get(s.board)
+	if !ok {
+		return
+	}
+	for idx, state := range states {
+		if state.Init == move {
+			copy(states[1:idx+1], states[:idx])
+			states[0] = state
+			break
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package ghess
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestParallelSearch(t *testing.T) {
+	game := NewBoard()
+	fen := "r1bqkb1r/1p3ppp/p1n2n2/3p4/8/1N1B4/PPP2PPP/RNBQ1RK1 w kq - 0 9"
+	_ = game.LoadFen(fen)
+	s := GetState(&game)
+	single, err := MiniMaxPruning(0, 3, s)
+	if err != nil {
+		t.Error(err)
+	}
+
+	s.SetOptions(SearchOptions{Threads: 4})
+	parallel, err := MiniMaxPruning(0, 3, s)
+	if err != nil {
+		t.Error(err)
+	}
+	if single.eval != parallel.eval {
+		t.Error("Parallel search scores", parallel.eval,
+			"expected", single.eval)
+	}
+	// The best move is remembered for the next search
+	move, ok := pvMap.get(&game)
+	if !ok || move != parallel.Init {
+		t.Error("Best move not in pvMap", move)
+	}
+}
+
+func TestParallelCheckMate(t *testing.T) {
+	game := NewBoard()
+	fen := `4k3/8/8/8/8/7r/6r1/1K6 b - - 0 2`
+	_ = game.LoadFen(fen)
+	s := GetState(&game)
+	s.SetOptions(SearchOptions{Threads: 8})
+	nxt, err := MiniMaxPruning(0, 2, s)
+	if err != nil {
+		t.Error(err)
+	}
+	err = game.Move(nxt.Init[0], nxt.Init[1])
+	if err != nil {
+		t.Error(err)
+	}
+	if !game.Checkmate {
+		t.Error("Parallel search missed the Checkmate")
+	}
+}
+
+func TestHashTableConcurrent(t *testing.T) {
+	table := &hashTable{m: make(map[[120]byte]int)}
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			var key [120]byte
+			key[i] = 'P'
+			table.put(key, i)
+			_, _ = table.get(key)
+		}(i)
+	}
+	wg.Wait()
+	if table.len() != 8 {
+		t.Error("Expected 8 entries, got", table.len())
+	}
+}
@@ -0,0 +1,184 @@
+// Package ghess is a chess engine. This file concerns the
+// weights of the evaluation, which can be read from a JSON
+// file for trying out different values without recompiling.
+package ghess
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+// EvalParams holds every weight and table of the evaluation.
+// Pieces are keyed by their upper case letter, P N B R Q K,
+// and Black uses the White weights. See DefaultParams for the
+// layout of the tables. Phase weights aren't included, they
+// describe the game rather than score it.
+//
+// After changing any field, call Prepare before evaluating.
+type EvalParams struct {
+	Material  map[string]int     `json:"material"`
+	MidTables map[string][64]int `json:"midTables"`
+	EndTables map[string][64]int `json:"endTables"`
+	Draw      int                `json:"draw"` // penalty for a drawn position
+
+	DoubledPawn    [2]int    `json:"doubledPawn"`
+	IsolatedPawn   [2]int    `json:"isolatedPawn"`
+	BackwardPawn   [2]int    `json:"backwardPawn"`
+	ConnectedPawn  [2]int    `json:"connectedPawn"`
+	PassedPawn     [9][2]int `json:"passedPawn"`
+	FreePassedPawn [9][2]int `json:"freePassedPawn"`
+
+	MobilityWeight   map[string][2]int `json:"mobilityWeight"`
+	MobilityBase     map[string]int    `json:"mobilityBase"`
+	RookOpenFile     [2]int            `json:"rookOpenFile"`
+	RookHalfOpenFile [2]int            `json:"rookHalfOpenFile"`
+	RookSeventh      [2]int            `json:"rookSeventh"`
+	KnightOutpost    [2]int            `json:"knightOutpost"`
+	BishopOutpost    [2]int            `json:"bishopOutpost"`
+	BishopPair       [2]int            `json:"bishopPair"`
+
+	KingShelter      [3]int         `json:"kingShelter"`
+	KingNoPawn       int            `json:"kingNoPawn"`
+	KingOpenFile     int            `json:"kingOpenFile"`
+	PawnStorm        [4]int         `json:"pawnStorm"`
+	KingAttackWeight map[string]int `json:"kingAttackWeight"`
+	KingDangerMax    int            `json:"kingDangerMax"`
+
+	// Lookups by piece byte, built by Prepare
+	material   [128]int
+	pst        [128][120]phaseScore // by piece and Board coordinate
+	mobility   [128][2]int
+	mobileBase [128]int
+	kingAttack [128]int
+	pawns      *pawnTable // the pawn scores depend on the weights
+}
+
+// defaultParams are used by Evaluate.
+var defaultParams = DefaultParams()
+
+// Prepare checks the pieces named in the maps, builds
+// the lookup tables, and clears the pawn structure cache.
+// It must not be called while evaluating.
+func (ep *EvalParams) Prepare() error {
+	ep.material = [128]int{}
+	ep.pst = [128][120]phaseScore{}
+	ep.mobility = [128][2]int{}
+	ep.mobileBase = [128]int{}
+	ep.kingAttack = [128]int{}
+	ep.pawns = nil // until prepared
+
+	for key, val := range ep.Material {
+		white, black, err := pieceKey(key)
+		if err != nil {
+			return err
+		}
+		ep.material[white], ep.material[black] = val, val
+	}
+	for phase, tables := range [2]map[string][64]int{ep.MidTables, ep.EndTables} {
+		for key, table := range tables {
+			white, black, err := pieceKey(key)
+			if err != nil {
+				return err
+			}
+			for i, val := range table {
+				idx := tableSquare(i)
+				ep.pst[white][idx][phase] = val
+				ep.pst[black][mirrorSquare(idx)][phase] = val
+			}
+		}
+	}
+	for key, val := range ep.MobilityWeight {
+		white, _, err := pieceKey(key)
+		if err != nil {
+			return err
+		}
+		ep.mobility[white] = val
+	}
+	for key, val := range ep.MobilityBase {
+		white, _, err := pieceKey(key)
+		if err != nil {
+			return err
+		}
+		ep.mobileBase[white] = val
+	}
+	for key, val := range ep.KingAttackWeight {
+		white, _, err := pieceKey(key)
+		if err != nil {
+			return err
+		}
+		ep.kingAttack[white] = val
+	}
+	ep.pawns = &pawnTable{m: make(map[pawnKey]pawnEntry)}
+	return nil
+}
+
+// prepareMu guards preparing EvalParams on first use.
+var prepareMu sync.Mutex
+
+// prepared prepares ep for a search if Prepare wasn't
+// called, as for EvalParams built by hand.
+func (ep *EvalParams) prepared() error {
+	prepareMu.Lock()
+	defer prepareMu.Unlock()
+	if ep.pawns != nil {
+		return nil
+	}
+	return ep.Prepare()
+}
+
+// pieceKey returns the White and Black bytes of a piece key.
+func pieceKey(key string) (byte, byte, error) {
+	if len(key) == 1 {
+		if lower, ok := ByteToLower[key[0]]; ok && key[0] != lower {
+			return key[0], lower, nil
+		}
+	}
+	return 0, 0, fmt.Errorf("Unknown piece %q, use one of PNBRQK", key)
+}
+
+// ReadParams reads EvalParams as JSON. Weights missing
+// from the JSON keep their DefaultParams value.
+func ReadParams(r io.Reader) (*EvalParams, error) {
+	ep := DefaultParams()
+	if err := json.NewDecoder(r).Decode(ep); err != nil {
+		return nil, err
+	}
+	if err := ep.Prepare(); err != nil {
+		return nil, err
+	}
+	return ep, nil
+}
+
+// LoadParams reads EvalParams from a JSON file, see ReadParams.
+func LoadParams(path string) (*EvalParams, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ReadParams(f)
+}
+
+// WriteJSON writes the EvalParams as indented JSON.
+func (ep *EvalParams) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "\t")
+	return enc.Encode(ep)
+}
+
+// Save writes the EvalParams to a JSON file, which
+// LoadParams reads back.
+func (ep *EvalParams) Save(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := ep.WriteJSON(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,112 @@
+package ghess
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDefaultParams(t *testing.T) {
+	game := NewBoard()
+	_ = game.LoadFen(`r1bqkb1r/pppp1ppp/2n2n2/4p3/2B1P3/5N2/PPPP1PPP/RNBQK2R w KQkq - 4 4`)
+	if DefaultParams().Evaluate(&game) != game.Evaluate() {
+		t.Error("DefaultParams should evaluate the same as Evaluate")
+	}
+}
+
+func TestReadParams(t *testing.T) {
+	ep, err := ReadParams(strings.NewReader(`{"material": {"N": 400}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ep.Material["B"] != 330 {
+		t.Error("Missing weights should keep the default", ep.Material)
+	}
+	game := NewBoard()
+	// White is a Knight up
+	_ = game.LoadFen(`4k3/p7/8/8/8/8/P7/1N2K3 w - - 0 40`)
+	if diff := ep.Evaluate(&game) - game.Evaluate(); diff != 80 {
+		t.Error("Knight should be worth 80 more, got", diff)
+	}
+	_, err = ReadParams(strings.NewReader(`{"material": {"X": 400}}`))
+	if err == nil {
+		t.Error("Unknown piece should be an error")
+	}
+}
+
+func TestSaveParams(t *testing.T) {
+	ep := DefaultParams()
+	ep.MidTables["N"] = [64]int{}
+	ep.KingDangerMax = 100
+	if err := ep.Prepare(); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "params.json")
+	if err := ep.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadParams(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var want, got bytes.Buffer
+	_ = ep.WriteJSON(&want)
+	_ = loaded.WriteJSON(&got)
+	if want.String() != got.String() {
+		t.Error("Saved and loaded params differ")
+	}
+	if _, err := LoadParams(filepath.Join(t.TempDir(), "none.json")); !os.IsNotExist(err) {
+		t.Error("Missing file should be an error", err)
+	}
+}
+
+func TestSearchParams(t *testing.T) {
+	game := NewBoard()
+	// Winning the Knight or the Rook, with Rooks worth nothing
+	_ = game.LoadFen(`4k3/8/8/3n1r2/4P3/8/8/4K3 w - - 0 40`)
+	ep := DefaultParams()
+	ep.Material["R"] = 0
+	if err := ep.Prepare(); err != nil {
+		t.Fatal(err)
+	}
+	s := GetState(&game)
+	opts := SearchOptions{NoDictionary: true, Params: ep}
+	s.SetOptions(opts)
+	best, err := MiniMaxPruning(0, 1, s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if best.Init != [2]int{44, 55} {
+		t.Error("Should take the Knight on d5, got", best.Init)
+	}
+	s.SetOptions(SearchOptions{NoDictionary: true})
+	best, _ = MiniMaxPruning(0, 1, s)
+	if best.Init != [2]int{44, 53} {
+		t.Error("Should take the Rook on f5, got", best.Init)
+	}
+}
+
+func TestUnpreparedParams(t *testing.T) {
+	game := NewBoard()
+	_ = game.LoadFen(`4k3/pp6/8/2r1n3/3P4/8/PP6/4K3 w - - 0 40`)
+	// Not prepared, the pawn cache is nil
+	(&EvalParams{}).Evaluate(&game)
+
+	ep := &EvalParams{Material: map[string]int{"N": 300, "R": 0}}
+	s := GetState(&game)
+	s.SetOptions(SearchOptions{NoDictionary: true, Params: ep, Threads: 2})
+	best, err := MiniMaxPruning(0, 1, s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if best.Init != [2]int{45, 54} {
+		t.Error("Should take the Knight on e5, got", best.Init)
+	}
+
+	s.SetOptions(SearchOptions{NoDictionary: true, Params: &EvalParams{Material: map[string]int{"X": 1}}})
+	if _, err := MiniMaxPruning(0, 1, s); err == nil {
+		t.Error("Unknown piece should be an error")
+	}
+}
@@ -183,26 +183,16 @@ func (b *Board) ParseMove(move string) error {
 			dest+19, dest+12, dest+8, dest-8,
 			dest-12, dest-19, dest-21
 		for _, possibility := range possibilities {
-			if column != 0 { // Disambiguate
-				disambig := possibility%10 == column
-				if b.board[possibility] == target && disambig {
-					orig = possibility
-					break
-				}
-			} else if row[0] != 0 { // Disambiguate
-				for _, r := range row {
-					disambig := b.board[possibility] == target &&
-						b.board[possibility] == byte(r)
-					if disambig {
-						orig = possibility
-						break
-					}
-				}
-			} else {
-				if b.board[possibility] == target {
-					orig = possibility
-					break
-				}
+			if b.board[possibility] != target ||
+				column != 0 && possibility%10 != column || // Disambiguate
+				row[0] != 0 && possibility/10 != row[0]/10 {
+				continue
+			}
+			// SAN leaves out a pinned Knight, which can't move
+			orig = possibility
+			try := *b
+			if try.Move(possibility, dest) == nil {
+				break
 			}
 		}
 	case piece == "B": // Bishop Parse
@@ -2,6 +2,7 @@ package ghess
 
 import (
 	"fmt"
+	"strings"
 	"testing"
 )
 
@@ -120,6 +121,33 @@ func ExampleBoard_ParseStand() {
 	// rnbqkb1r/pppppppp/5n2/8/4P3/8/PPPP1PPP/RNBQKBNR w KQkq - 0 2
 }
 
+func TestParseKnight(t *testing.T) {
+	for _, moves := range []string{
+		// The c3 Knight is pinned, so Ne2 is the g1 Knight
+		"e4 e6 d4 d5 Nc3 Bb4 Ne2",
+		// Knights told apart by their rank
+		"e4 c6 d4 d5 Nd2 dxe4 Nxe4 Nd7 Ng5 Ngf6 Bd3 e6 N1f3",
+	} {
+		game := NewBoard()
+		for _, move := range strings.Fields(moves) {
+			if err := game.ParseMove(move); err != nil {
+				t.Fatal(moves, move, err)
+			}
+		}
+	}
+}
+
+func TestLoadFenClocks(t *testing.T) {
+	game := NewBoard()
+	fen := "8/8/4k3/8/8/4K3/4P3/8 w - - 37 120"
+	if err := game.LoadFen(fen); err != nil {
+		t.Fatal("Two digit halfmove clock", err)
+	}
+	if game.moves != 120 {
+		t.Error("Should be move 120, not", game.moves)
+	}
+}
+
 func ExampleBoard_LoadFen() {
 	game := NewBoard()
 	fen := "6Q1/8/8/p7/k7/5p2/1K6/8 w ---- - 0 5"
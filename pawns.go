@@ -0,0 +1,216 @@
+// Package ghess is a chess engine. This file concerns the
+// evaluation of the pawn structure.
+package ghess
+
+import (
+	"sync"
+)
+
+// pawnKey is the placement of the pawns, one bit per square.
+type pawnKey struct {
+	white, black uint64
+}
+
+// pawnEntry is the pawn structure score of a pawnKey.
+// Passed pawns are kept for checking their path, which
+// depends on the other pieces as well.
+type pawnEntry struct {
+	score  [2]phaseScore // White and Black
+	passed []int         // squares of the passed pawns
+}
+
+// pawnTable caches pawnEntries, each EvalParams has
+// its own, shared by every search goroutine.
+type pawnTable struct {
+	sync.RWMutex
+	m map[pawnKey]pawnEntry
+}
+
+// pawnTableSize is the most entries in a pawnTable
+// before it is cleared.
+const pawnTableSize = 1 << 14
+
+// A nil pawnTable, of unprepared EvalParams, caches nothing.
+func (t *pawnTable) get(key pawnKey) (pawnEntry, bool) {
+	if t == nil {
+		return pawnEntry{}, false
+	}
+	t.RLock()
+	defer t.RUnlock()
+	entry, ok := t.m[key]
+	return entry, ok
+}
+
+func (t *pawnTable) put(key pawnKey, entry pawnEntry) {
+	if t == nil {
+		return
+	}
+	t.Lock()
+	if len(t.m) >= pawnTableSize {
+		t.m = make(map[pawnKey]pawnEntry)
+	}
+	t.m[key] = entry
+	t.Unlock()
+}
+
+// square64 turns a Board coordinate into 0 - 63.
+func square64(idx int) uint {
+	return uint((idx/10-1)*8 + idx%10 - 1)
+}
+
+// pawnKey returns the key of the pawn placement.
+func (b *Board) pawnKey() pawnKey {
+	var key pawnKey
+	for idx := 21; idx < 79; idx++ {
+		switch b.board[idx] {
+		case 'P':
+			key.white |= 1 << square64(idx)
+		case 'p':
+			key.black |= 1 << square64(idx)
+		}
+	}
+	return key
+}
+
+// evaluatePawns returns the Middle Game and End Game scores
+// of the pawn structure for White and Black, looked up in
+// the pawn cache of ep if possible. The passed pawn path is
+// checked every time, as it isn't only about pawns.
+func (b *Board) evaluatePawns(ep *EvalParams) [2]phaseScore {
+	key := b.pawnKey()
+	entry, ok := ep.pawns.get(key)
+	if !ok {
+		entry = b.pawnStructure(ep)
+		ep.pawns.put(key, entry)
+	}
+	score := entry.score
+	for _, idx := range entry.passed {
+		side, step, rank := 0, 10, idx/10
+		if b.board[idx] == 'p' {
+			side, step, rank = 1, -10, 9-idx/10
+		}
+		free := true
+		for sq := idx + step; sq > 10 && sq < 89; sq += step {
+			if b.board[sq] != '.' {
+				free = false
+				break
+			}
+		}
+		if free {
+			score[side].add(ep.FreePassedPawn[rank][0], ep.FreePassedPawn[rank][1])
+		}
+	}
+	return score
+}
+
+// pawnStructure scores doubled, isolated, backward, connected
+// and passed pawns. Files are counted 1 for h to 8 for a, the
+// same as the Board coordinates.
+func (b *Board) pawnStructure(ep *EvalParams) pawnEntry {
+	var entry pawnEntry
+	var files [2][10]int // pawns on each file, by side
+	for idx := 21; idx < 79; idx++ {
+		switch b.board[idx] {
+		case 'P':
+			files[0][idx%10]++
+		case 'p':
+			files[1][idx%10]++
+		}
+	}
+	// Doubled pawns
+	for side := 0; side < 2; side++ {
+		for file := 1; file < 9; file++ {
+			if files[side][file] > 1 {
+				extra := files[side][file] - 1
+				entry.score[side].add(ep.DoubledPawn[0]*extra,
+					ep.DoubledPawn[1]*extra)
+			}
+		}
+	}
+
+	for idx := 21; idx < 79; idx++ {
+		val := b.board[idx]
+		if val != 'P' && val != 'p' {
+			continue
+		}
+		isWhite := val == 'P'
+		side := 0
+		if !isWhite {
+			side = 1
+		}
+		score := &entry.score[side]
+		file := idx % 10
+		isolated := files[side][file-1] == 0 && files[side][file+1] == 0
+		switch {
+		case isolated:
+			score.add(ep.IsolatedPawn[0], ep.IsolatedPawn[1])
+		case b.pawnBackward(idx, isWhite):
+			score.add(ep.BackwardPawn[0], ep.BackwardPawn[1])
+		}
+		if b.pawnConnected(idx, isWhite) {
+			score.add(ep.ConnectedPawn[0], ep.ConnectedPawn[1])
+		}
+		if b.pawnPassed(idx, isWhite) {
+			rank := idx / 10
+			if !isWhite {
+				rank = 9 - rank
+			}
+			score.add(ep.PassedPawn[rank][0], ep.PassedPawn[rank][1])
+			entry.passed = append(entry.passed, idx)
+		}
+	}
+	return entry
+}
+
+// pawnPassed returns true if no enemy pawn can stop
+// the pawn on idx, on its own or the adjacent files.
+func (b *Board) pawnPassed(idx int, isWhite bool) bool {
+	for _, file := range [3]int{idx - 1, idx, idx + 1} {
+		if isWhite {
+			for sq := file + 10; sq < 89; sq += 10 {
+				if b.board[sq] == 'p' {
+					return false
+				}
+			}
+		} else {
+			for sq := file - 10; sq > 10; sq -= 10 {
+				if b.board[sq] == 'P' {
+					return false
+				}
+			}
+		}
+	}
+	return true
+}
+
+// pawnConnected returns true if the pawn on idx stands
+// beside or is protected by a friendly pawn.
+func (b *Board) pawnConnected(idx int, isWhite bool) bool {
+	pawn := byte('P')
+	if !isWhite {
+		pawn = 'p'
+	}
+	if b.board[idx-1] == pawn || b.board[idx+1] == pawn {
+		return true
+	}
+	return b.pawnProtect(idx, isWhite)
+}
+
+// pawnBackward returns true if the pawn on idx has no friendly
+// pawns beside or behind it on the adjacent files, and can't
+// advance without being taken by an enemy pawn.
+func (b *Board) pawnBackward(idx int, isWhite bool) bool {
+	pawn, step := byte('P'), -10
+	if !isWhite {
+		pawn, step = 'p', 10
+	}
+	// Look for support on the adjacent files
+	for _, sq := range [2]int{idx - 1, idx + 1} {
+		for ; sq > 10 && sq < 89; sq += step {
+			if b.board[sq] == pawn {
+				return false
+			}
+		}
+	}
+	return b.pawnThreat(idx-step, isWhite)
+}
@@ -0,0 +1,80 @@
+package ghess
+
+import (
+	"testing"
+)
+
+func TestPawnStructure(t *testing.T) {
+	game := NewBoard()
+	// White has doubled isolated c pawns, Black is healthy
+	_ = game.LoadFen(`4k3/pp3ppp/8/8/8/2P5/2P2PPP/4K3 w - - 0 30`)
+	entry := game.pawnStructure(defaultParams)
+	white, black := entry.score[0], entry.score[1]
+	if white[0] >= black[0] || white[1] >= black[1] {
+		t.Error("Doubled isolated pawns should be bad", entry)
+	}
+	// Only a7, no White pawn on the a or b files
+	if len(entry.passed) != 1 || entry.passed[0] != 78 {
+		t.Error("Only a7 is passed", entry.passed)
+	}
+}
+
+func TestPassedPawn(t *testing.T) {
+	game := NewBoard()
+	_ = game.LoadFen(`4k3/8/8/3P4/8/8/8/4K3 w - - 0 50`)
+	if !game.pawnPassed(55, true) {
+		t.Error("d5 is passed")
+	}
+	far := game.evaluatePawns(defaultParams)[0][1]
+	_ = game.LoadFen(`4k3/8/8/8/8/3P4/8/4K3 w - - 0 50`)
+	near := game.evaluatePawns(defaultParams)[0][1]
+	if far <= near {
+		t.Error("Passed pawn bonus should grow with rank", far, near)
+	}
+	// A blocked passed pawn loses its free path bonus
+	_ = game.LoadFen(`4k3/8/3n4/3P4/8/8/8/4K3 w - - 0 50`)
+	blocked := game.evaluatePawns(defaultParams)[0][1]
+	if blocked >= far {
+		t.Error("Blocked passed pawn should score less", blocked, far)
+	}
+	// An enemy pawn on an adjacent file stops it
+	_ = game.LoadFen(`4k3/4p3/8/3P4/8/8/8/4K3 w - - 0 50`)
+	if game.pawnPassed(55, true) {
+		t.Error("d5 isn't passed with a pawn on e7")
+	}
+}
+
+func TestBackwardPawn(t *testing.T) {
+	game := NewBoard()
+	// d3 can't be supported and d4 is attacked by c5
+	_ = game.LoadFen(`4k3/8/8/2p5/4P3/3P4/8/4K3 w - - 0 30`)
+	if !game.pawnBackward(35, true) {
+		t.Error("d3 is backward")
+	}
+	if game.pawnBackward(44, true) {
+		t.Error("e4 is supported by d3")
+	}
+}
+
+func TestPawnHash(t *testing.T) {
+	game := NewBoard()
+	_ = game.LoadFen(`4k3/pp3ppp/8/8/8/2P5/2P2PPP/4K3 w - - 0 30`)
+	score := game.evaluatePawns(defaultParams)
+	entry, ok := defaultParams.pawns.get(game.pawnKey())
+	if !ok {
+		t.Fatal("Pawn structure not cached")
+	}
+	// Moving a king doesn't change the key
+	key := game.pawnKey()
+	_ = game.Move(14, 15)
+	if game.pawnKey() != key {
+		t.Error("Key changed by a King move")
+	}
+	if game.evaluatePawns(defaultParams) != score {
+		t.Error("Cached score differs", score, game.evaluatePawns(defaultParams))
+	}
+	// a7 has a free path, on top of the cached structure
+	if entry.score[1][1] >= score[1][1] {
+		t.Error("Free path bonus missing", entry.score, score)
+	}
+}
@@ -0,0 +1,163 @@
+// Package ghess is a chess engine. This file concerns reading
+// PGN files of many games, for building books and the like.
+package ghess
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// PgnGame is a game read from a PGN file.
+type PgnGame struct {
+	Tags   map[string]string // tag pairs, such as White and ECO
+	Moves  []string          // SAN moves, without numbers or comments
+	Result string            // 1-0, 0-1, 1/2-1/2 or * if unknown
+}
+
+var (
+	pgnTagPattern  = regexp.MustCompile(`^\[(\w+)\s+"(.*)"\]$`)
+	pgnMoveNumber  = regexp.MustCompile(`^\d+\.+`)
+	pgnResultToken = map[string]bool{"1-0": true, "0-1": true, "1/2-1/2": true, "*": true}
+)
+
+// ReadPgn reads every game of a PGN file. Comments, variations
+// and annotations are left out of the moves.
+func ReadPgn(r io.Reader) ([]PgnGame, error) {
+	var games []PgnGame
+	game := PgnGame{Tags: make(map[string]string)}
+	var movetext strings.Builder
+	finish := func() {
+		if len(game.Tags) == 0 && strings.TrimSpace(movetext.String()) == "" {
+			return
+		}
+		game.Moves, game.Result = pgnMoves(movetext.String())
+		if game.Result == "*" && game.Tags["Result"] != "" {
+			game.Result = game.Tags["Result"]
+		}
+		games = append(games, game)
+		game = PgnGame{Tags: make(map[string]string)}
+		movetext.Reset()
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "%") {
+			continue // escaped line
+		}
+		if tag := pgnTagPattern.FindStringSubmatch(line); tag != nil {
+			// Tags after moves start the next game
+			if strings.TrimSpace(movetext.String()) != "" {
+				finish()
+			}
+			game.Tags[tag[1]] = tag[2]
+			continue
+		}
+		movetext.WriteString(line)
+		movetext.WriteString("\n")
+		if fields := strings.Fields(line); len(fields) > 0 &&
+			pgnResultToken[fields[len(fields)-1]] && !strings.Contains(line, "{") {
+			finish()
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return games, err
+	}
+	finish()
+	return games, nil
+}
+
+// LoadPgnFile reads every game of the PGN file at path.
+func LoadPgnFile(path string) ([]PgnGame, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ReadPgn(f)
+}
+
+// pgnMoves returns the moves and result of PGN movetext.
+func pgnMoves(text string) ([]string, string) {
+	var moves []string
+	result := "*"
+	var depth int // of variations
+	for i := 0; i < len(text); i++ {
+		switch c := text[i]; {
+		case c == '{':
+			if end := strings.IndexByte(text[i:], '}'); end >= 0 {
+				i += end
+			} else {
+				i = len(text)
+			}
+		case c == ';':
+			if end := strings.IndexByte(text[i:], '\n'); end >= 0 {
+				i += end
+			} else {
+				i = len(text)
+			}
+		case c == '(':
+			depth++
+		case c == ')':
+			if depth > 0 {
+				depth--
+			}
+		case c == ' ' || c == '\n' || c == '\t' || c == '\r':
+		default:
+			end := i
+			for end < len(text) && !strings.ContainsRune(" \n\t\r{}();", rune(text[end])) {
+				end++
+			}
+			token := text[i:end]
+			i = end - 1
+			if depth > 0 || strings.HasPrefix(token, "$") {
+				continue
+			}
+			if pgnResultToken[token] {
+				result = token
+				continue
+			}
+			token = pgnMoveNumber.ReplaceAllString(token, "")
+			token = strings.TrimRight(token, "+#!?")
+			if token == "0-0" || token == "0-0-0" {
+				token = strings.Replace(token, "0", "O", -1)
+			}
+			if token != "" {
+				moves = append(moves, token)
+			}
+		}
+	}
+	return moves, result
+}
+
+// Replay plays the game's moves on a new Board, calling played,
+// if not nil, for each move with the board before the move and
+// the move's coordinates. It stops after plies plies, or at the
+// end of the game if plies is 0.
+func (g PgnGame) Replay(plies int, played func(b *Board, orig, dest int)) (*Board, error) {
+	game := NewBoard()
+	b := &game
+	if fen, ok := g.Tags["FEN"]; ok {
+		if err := b.LoadFen(fen); err != nil {
+			return b, err
+		}
+	}
+	for i, move := range g.Moves {
+		if plies > 0 && i >= plies {
+			break
+		}
+		before := *b
+		if err := b.ParseMove(move); err != nil {
+			return b, fmt.Errorf("Move %d %s: %v", i/2+1, move, err)
+		}
+		if played != nil {
+			played(&before, b.History[0], b.History[1])
+		}
+	}
+	return b, nil
+}
@@ -0,0 +1,69 @@
+package ghess
+
+import (
+	"strings"
+	"testing"
+)
+
+const testPgn = `[Event "Test"]
+[White "A"]
+[Black "B"]
+[Result "1-0"]
+
+1. e4 e5 {the open game} 2. Nf3 (2. f4 exf4) Nc6 $1 3. Bb5 a6?!
+4. Ba4 Nf6 5. 0-0 1-0
+
+[Event "Test"]
+[Result "1/2-1/2"]
+
+1. d4 d5 2. c4 ; the Queen's Gambit
+2... e6 1/2-1/2
+`
+
+func TestReadPgn(t *testing.T) {
+	games, err := ReadPgn(strings.NewReader(testPgn))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(games) != 2 {
+		t.Fatal("Should read 2 games, got", len(games))
+	}
+	moves := strings.Join(games[0].Moves, " ")
+	if moves != "e4 e5 Nf3 Nc6 Bb5 a6 Ba4 Nf6 O-O" {
+		t.Error("Wrong moves", moves)
+	}
+	if games[0].Tags["White"] != "A" || games[0].Result != "1-0" {
+		t.Error("Wrong tags or result", games[0].Tags, games[0].Result)
+	}
+	if moves := strings.Join(games[1].Moves, " "); moves != "d4 d5 c4 e6" {
+		t.Error("Wrong moves", moves)
+	}
+	if games[1].Result != "1/2-1/2" {
+		t.Error("Should be drawn", games[1].Result)
+	}
+}
+
+func TestReplay(t *testing.T) {
+	games, _ := ReadPgn(strings.NewReader(testPgn))
+	var plies int
+	b, err := games[0].Replay(0, func(b *Board, orig, dest int) {
+		if plies == 0 && (orig != 24 || dest != 44) {
+			t.Error("First move should be e2e4", orig, dest)
+		}
+		plies++
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plies != 9 || b.board[12] != 'K' {
+		t.Error("Should have castled after 9 plies", plies)
+	}
+	b, _ = games[0].Replay(2, nil)
+	if b.toMove != "w" || b.board[54] != 'p' {
+		t.Error("Should stop after 1. e4 e5")
+	}
+	bad := PgnGame{Moves: []string{"e4", "Ke7", "Qh5"}}
+	if _, err := bad.Replay(0, nil); err == nil {
+		t.Error("Illegal move should be an error")
+	}
+}
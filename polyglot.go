@@ -0,0 +1,246 @@
+// Package ghess is a chess engine. This file concerns Polyglot
+// opening books, .bin files of 16 byte entries sorted by the
+// Polyglot key of the position: the key, the move and its
+// weight, big-endian. The keys hash the position with the 781
+// random numbers of the specification, in polyglot_random.go.
+package ghess
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Offsets into the random numbers, after the 12 * 64 pieces.
+const (
+	polyglotCastle    = 768 // KQkq
+	polyglotEmpassant = 772 // by file
+	polyglotTurn      = 780 // White to move
+	polyglotRandoms   = 781
+)
+
+// polyglotPieces orders the pieces of the key, Black first.
+const polyglotPieces = "pPnNbBrRqQkK"
+
+// ErrNotInBook is returned when the position isn't in the book.
+var ErrNotInBook = errors.New("Position not in the book")
+
+// polyglotSquare returns the Polyglot square of a Board
+// coordinate, 0 - 63 from a1 to h8 by rank.
+func polyglotSquare(idx int) int {
+	return (idx/10-1)*8 + 8 - idx%10
+}
+
+// boardSquare returns the Board coordinate of a Polyglot square.
+func boardSquare(sq int) int {
+	return (sq/8+1)*10 + 8 - sq%8
+}
+
+// PolyglotKey returns the key of the position in a Polyglot book.
+func (b *Board) PolyglotKey() uint64 {
+	random := polyglotRandom
+	var key uint64
+	for idx, val := range b.board {
+		if val == '.' || val == ' ' {
+			continue
+		}
+		kind := strings.IndexByte(polyglotPieces, val)
+		if kind < 0 {
+			continue
+		}
+		key ^= random[64*kind+polyglotSquare(idx)]
+	}
+	for i, c := range b.castle {
+		if c != '-' {
+			key ^= random[polyglotCastle+i]
+		}
+	}
+	// Only when a pawn can take en passant
+	if b.empassant != 0 {
+		pawn := byte('p')
+		if b.toMove == "w" {
+			pawn = 'P'
+		}
+		if b.board[b.empassant+1] == pawn || b.board[b.empassant-1] == pawn {
+			key ^= random[polyglotEmpassant+polyglotSquare(b.empassant)%8]
+		}
+	}
+	if b.toMove == "w" {
+		key ^= random[polyglotTurn]
+	}
+	return key
+}
+
+// BookMove is a move in a book, and its weight.
+type BookMove struct {
+	Orig, Dest int // Board coordinates
+	Weight     int
+}
+
+// polyglotEntry is an entry of a book file.
+type polyglotEntry struct {
+	Key    uint64
+	Move   uint16
+	Weight uint16
+	Learn  uint32
+}
+
+// Book is a Polyglot opening book. Set SearchOptions.Book
+// to play from it at the root of the search.
+type Book struct {
+	entries []polyglotEntry // sorted by key
+}
+
+// ReadBook reads a Polyglot book.
+func ReadBook(r io.Reader) (*Book, error) {
+	var entries []polyglotEntry
+	br := bufio.NewReader(r)
+	for {
+		var entry polyglotEntry
+		err := binary.Read(br, binary.BigEndian, &entry)
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("Reading book entry %d: %v", len(entries), err)
+		}
+		entries = append(entries, entry)
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].Key < entries[j].Key
+	})
+	return &Book{entries: entries}, nil
+}
+
+// OpenBook reads the Polyglot book at path.
+func OpenBook(path string) (*Book, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ReadBook(f)
+}
+
+// Write writes the book as a Polyglot .bin file.
+func (bk *Book) Write(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	if err := binary.Write(bw, binary.BigEndian, bk.entries); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+// Save writes the book to a Polyglot file at path,
+// which OpenBook reads back.
+func (bk *Book) Save(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := bk.Write(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Len returns the number of entries in the book.
+func (bk *Book) Len() int {
+	return len(bk.entries)
+}
+
+// Moves returns the book's moves in the position, the highest
+// weight first. Moves the board can't play, such as promotions
+// to other than a Queen, are left out.
+func (bk *Book) Moves(b *Board) []BookMove {
+	key := b.PolyglotKey()
+	i := sort.Search(len(bk.entries), func(i int) bool {
+		return bk.entries[i].Key >= key
+	})
+	var moves []BookMove
+	for ; i < len(bk.entries) && bk.entries[i].Key == key; i++ {
+		orig, dest, ok := polyglotMove(bk.entries[i].Move)
+		if !ok || CopyBoard(b).Move(orig, dest) != nil {
+			continue
+		}
+		moves = append(moves, BookMove{orig, dest, int(bk.entries[i].Weight)})
+	}
+	sort.SliceStable(moves, func(i, j int) bool {
+		return moves[i].Weight > moves[j].Weight
+	})
+	return moves
+}
+
+// Pick returns the book move of highest weight, or if random
+// picks one at random in proportion to the weights.
+func (bk *Book) Pick(b *Board, random bool) (BookMove, error) {
+	moves := bk.Moves(b)
+	if len(moves) == 0 {
+		return BookMove{}, ErrNotInBook
+	}
+	if !random {
+		return moves[0], nil
+	}
+	var total int
+	for _, m := range moves {
+		total += m.Weight
+	}
+	if total == 0 {
+		return moves[rand.Intn(len(moves))], nil
+	}
+	n := rand.Intn(total)
+	for _, m := range moves {
+		if n < m.Weight {
+			return m, nil
+		}
+		n -= m.Weight
+	}
+	return moves[0], nil
+}
+
+// polyglotMove decodes a book move into Board coordinates.
+// Polyglot castles as the King taking its own Rook, as Move
+// does. It returns false for promotions to other than a Queen.
+func polyglotMove(move uint16) (int, int, bool) {
+	dest := boardSquare(int(move & 0x3F))
+	orig := boardSquare(int(move >> 6 & 0x3F))
+	if promotion := move >> 12 & 7; promotion != 0 && promotion != 4 {
+		return 0, 0, false
+	}
+	return orig, dest, true
+}
+
+// polyglotEncode encodes a move of the board as a book
+// move, see polyglotMove.
+func (b *Board) polyglotEncode(orig, dest int) uint16 {
+	piece := b.board[orig]
+	move := uint16(polyglotSquare(orig))<<6 | uint16(polyglotSquare(dest))
+	if (piece == 'P' && dest > 80) || (piece == 'p' && dest < 20) {
+		move |= 4 << 12
+	}
+	return move
+}
+
+// bookState plays the book's move at the root of s.
+func (s State) bookState() (State, error) {
+	book, random := s.opts.Book, s.opts.BookRandom
+	m, err := book.Pick(s.board, random)
+	if err != nil {
+		return s, err
+	}
+	state, err := tryState(s.board, m.Orig, m.Dest, s.opts.evaluator())
+	if err != nil {
+		return s, err
+	}
+	state.Init = [2]int{m.Orig, m.Dest}
+	state.move = state.Init
+	state.isMax = s.isMax
+	state.opts = s.opts
+	return state, nil
+}
@@ -0,0 +1,160 @@
+package ghess
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strings"
+	"testing"
+)
+
+// testBook returns a book of the entries, in the
+// order given rather than sorted.
+func testBook(t *testing.T, entries ...polyglotEntry) *Book {
+	var buf bytes.Buffer
+	for _, entry := range entries {
+		if err := binary.Write(&buf, binary.BigEndian, entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+	book, err := ReadBook(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return book
+}
+
+func TestPolyglotSquare(t *testing.T) {
+	if sq := polyglotSquare(18); sq != 0 {
+		t.Error("a1 should be 0", sq)
+	}
+	if sq := polyglotSquare(81); sq != 63 {
+		t.Error("h8 should be 63", sq)
+	}
+	for sq := 0; sq < 64; sq++ {
+		if polyglotSquare(boardSquare(sq)) != sq {
+			t.Error("Square doesn't round trip", sq)
+		}
+	}
+}
+
+func TestPolyglotKey(t *testing.T) {
+	game := NewBoard()
+	start := game.PolyglotKey()
+	for _, move := range []string{"Nf3", "Nf6", "Ng1", "Ng8"} {
+		if err := game.ParseMove(move); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if key := game.PolyglotKey(); key != start {
+		t.Error("Transposition should have the same key")
+	}
+	_ = game.ParseMove("e4")
+	if game.PolyglotKey() == start {
+		t.Error("Different positions should have different keys")
+	}
+	// No Black pawn can take en passant
+	withEmpassant := game.PolyglotKey()
+	game.empassant = 0
+	if game.PolyglotKey() != withEmpassant {
+		t.Error("En passant only counts when it can be taken")
+	}
+	_ = game.LoadFen(`rnbqkbnr/ppp1pppp/8/8/3pP3/8/PPPP1PPP/RNBQKBNR b KQkq - 0 3`)
+	without := game.PolyglotKey()
+	game.empassant = 44 // e4
+	if game.PolyglotKey() == without {
+		t.Error("d4 pawn can take en passant")
+	}
+}
+
+func TestPolyglotReferenceKeys(t *testing.T) {
+	// The examples of the Polyglot specification
+	tests := []struct {
+		moves string
+		key   uint64
+	}{
+		{"", 0x463b96181691fc9c},
+		{"e2e4", 0x823c9b50fd114196},
+		{"e2e4 d7d5", 0x0756b94461c50fb0},
+		{"e2e4 d7d5 e4e5", 0x662fafb965db29d4},
+		{"e2e4 d7d5 e4e5 f7f5", 0x22a48b5a8e47ff78},
+		{"e2e4 d7d5 e4e5 f7f5 e1e2", 0x652a607ca3f242c1},
+		{"e2e4 d7d5 e4e5 f7f5 e1e2 e8f7", 0x00fdd303c946bdd9},
+		{"a2a4 b7b5 h2h4 b5b4 c2c4", 0x3c8123ea7b067637},
+		{"a2a4 b7b5 h2h4 b5b4 c2c4 b4c3 a1a3", 0x5c3f9b829b279560},
+	}
+	for _, test := range tests {
+		game := NewBoard()
+		for _, move := range strings.Fields(test.moves) {
+			orig, dest, err := game.ParseUCI(move)
+			if err == nil {
+				err = game.Move(orig, dest)
+			}
+			if err != nil {
+				t.Fatal(move, err)
+			}
+		}
+		if key := game.PolyglotKey(); key != test.key {
+			t.Errorf("%q should be %016x, not %016x", test.moves, test.key, key)
+		}
+	}
+}
+
+func TestBookMoves(t *testing.T) {
+	game := NewBoard()
+	key := game.PolyglotKey()
+	e4, d4 := game.polyglotEncode(24, 44), game.polyglotEncode(25, 45)
+	book := testBook(t,
+		polyglotEntry{Key: key + 1, Move: e4, Weight: 100},
+		polyglotEntry{Key: key, Move: e4, Weight: 5},
+		polyglotEntry{Key: key, Move: d4, Weight: 10},
+		polyglotEntry{Key: key, Move: game.polyglotEncode(24, 54), Weight: 50}, // illegal
+	)
+	moves := book.Moves(&game)
+	if len(moves) != 2 {
+		t.Fatal("Should be 2 book moves", moves)
+	}
+	if m, _ := book.Pick(&game, false); m.Orig != 25 || m.Dest != 45 {
+		t.Error("Heaviest move should be d4", m)
+	}
+	for i := 0; i < 10; i++ {
+		if m, err := book.Pick(&game, true); err != nil || (m.Dest != 44 && m.Dest != 45) {
+			t.Error("Random pick should be a book move", m, err)
+		}
+	}
+	_ = game.ParseMove("e4")
+	if _, err := book.Pick(&game, false); err != ErrNotInBook {
+		t.Error("Position shouldn't be in the book", err)
+	}
+}
+
+func TestBookCastle(t *testing.T) {
+	game := NewBoard()
+	_ = game.LoadFen(`r3k2r/pppppppp/8/8/8/8/PPPPPPPP/R3K2R w KQkq - 0 10`)
+	// Polyglot has the King take its Rook
+	move := uint16(polyglotSquare(14))<<6 | uint16(polyglotSquare(11))
+	if game.polyglotEncode(14, 11) != move {
+		t.Error("Castle should encode as e1h1")
+	}
+	book := testBook(t, polyglotEntry{Key: game.PolyglotKey(), Move: move, Weight: 1})
+	if m, err := book.Pick(&game, false); err != nil || m.Orig != 14 || m.Dest != 11 {
+		t.Error("Should castle King side", m, err)
+	}
+}
+
+func TestSearchBook(t *testing.T) {
+	game := NewBoard()
+	book := testBook(t, polyglotEntry{
+		Key:    game.PolyglotKey(),
+		Move:   game.polyglotEncode(25, 45),
+		Weight: 1,
+	})
+	s := GetState(&game)
+	s.SetOptions(SearchOptions{Book: book})
+	best, err := MiniMaxPruning(0, 2, s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if best.Init != [2]int{25, 45} {
+		t.Error("Should play d4 from the book, not the dictionary", best.Init)
+	}
+}
@@ -0,0 +1,81 @@
+// Package ghess is a chess engine. This file concerns writing
+// moves in standard algebraic notation, the notation of PGN.
+package ghess
+
+import "strings"
+
+// SAN returns a move of the board in standard algebraic
+// notation, such as Nbd2, exd5, O-O or e8=Q#. It returns an
+// empty string if the move isn't valid.
+func (b *Board) SAN(orig, dest int) string {
+	after := CopyBoard(b)
+	if err := after.Move(orig, dest); err != nil {
+		return ""
+	}
+	piece := b.board[orig]
+	target := b.board[dest]
+	var san string
+	switch {
+	case (piece == 'K' && target == 'R') || (piece == 'k' && target == 'r'):
+		// Castling is the King taking its own Rook
+		san = "O-O-O"
+		if dest < orig {
+			san = "O-O" // towards the h-file
+		}
+	case piece == 'P' || piece == 'p':
+		if orig%10 != dest%10 {
+			san = PieceMap[orig][:1] + "x" // en passant too
+		}
+		san += PieceMap[dest]
+		if dest > 80 || dest < 20 {
+			san += "=Q"
+		}
+	default:
+		san = strings.ToUpper(string(piece)) + b.disambiguate(orig, dest)
+		if target != '.' {
+			san += "x"
+		}
+		san += PieceMap[dest]
+	}
+	if after.Checkmate {
+		san += "#"
+	} else if after.Check {
+		san += "+"
+	}
+	return san
+}
+
+// ParseSAN returns the Board coordinates of a move in SAN
+// without playing it. Check and annotation marks are ignored.
+func (b *Board) ParseSAN(move string) (int, int, error) {
+	c := CopyBoard(b)
+	if err := c.ParseMove(strings.TrimRight(move, "+#!?")); err != nil {
+		return 0, 0, err
+	}
+	return c.History[0], c.History[1], nil
+}
+
+// disambiguate returns the file, rank or square of orig when
+// another piece of the same kind could move to dest.
+func (b *Board) disambiguate(orig, dest int) string {
+	origs, dests := b.SearchValid()
+	var others, sameFile, sameRank bool
+	for i, o := range origs {
+		if o == orig || dests[i] != dest || b.board[o] != b.board[orig] {
+			continue
+		}
+		others = true
+		sameFile = sameFile || o%10 == orig%10
+		sameRank = sameRank || o/10 == orig/10
+	}
+	square := PieceMap[orig]
+	switch {
+	case !others:
+		return ""
+	case !sameFile:
+		return square[:1]
+	case !sameRank:
+		return square[1:]
+	}
+	return square
+}
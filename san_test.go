@@ -0,0 +1,49 @@
+package ghess
+
+import "testing"
+
+func TestSAN(t *testing.T) {
+	tests := []struct {
+		fen        string
+		orig, dest int
+		san        string
+	}{
+		{"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1", 24, 44, "e4"},
+		{"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1", 12, 33, "Nf3"},
+		{"rnbqkbnr/pppppppp/8/8/8/8/PPPPPPPP/RNBQKBNR w KQkq - 0 1", 24, 54, ""},
+		{"r3k2r/pppppppp/8/8/8/8/PPPPPPPP/R3K2R w KQkq - 0 10", 14, 11, "O-O"},
+		{"r3k2r/pppppppp/8/8/8/8/PPPPPPPP/R3K2R w KQkq - 0 10", 14, 18, "O-O-O"},
+		{"4k3/8/8/8/8/5N2/8/1N2K3 w - - 0 1", 17, 25, "Nbd2"},
+		{"4k3/8/8/R7/8/8/8/R3K3 w - - 0 1", 18, 38, "R1a3"},
+		{"4k3/8/8/3p4/4P3/8/8/4K3 w - - 0 1", 44, 55, "exd5"},
+		{"6k1/5ppp/8/8/8/8/5PPP/3R2K1 w - - 0 1", 15, 85, "Rd8#"},
+		{"8/4P3/8/8/8/8/k7/4K3 w - - 0 60", 74, 84, "e8=Q"},
+	}
+	for _, test := range tests {
+		game := NewBoard()
+		if err := game.LoadFen(test.fen); err != nil {
+			t.Fatal(err)
+		}
+		if san := game.SAN(test.orig, test.dest); san != test.san {
+			t.Error(test.fen, "should be", test.san, "not", san)
+		}
+	}
+}
+
+func TestParseSAN(t *testing.T) {
+	game := NewBoard()
+	if orig, dest, err := game.ParseSAN("Nf3"); err != nil || orig != 12 || dest != 33 {
+		t.Error("Nf3 should be 12 33", orig, dest, err)
+	}
+	start := NewBoard()
+	if game.Position() != start.Position() {
+		t.Error("ParseSAN shouldn't play the move")
+	}
+	_ = game.LoadFen("6k1/5ppp/8/8/8/8/5PPP/3R2K1 w - - 0 1")
+	if orig, dest, err := game.ParseSAN("Rd8#"); err != nil || orig != 15 || dest != 85 {
+		t.Error("Rd8# should be 15 85", orig, dest, err)
+	}
+	if _, _, err := game.ParseSAN("Rd9"); err == nil {
+		t.Error("Rd9 should be an error")
+	}
+}
@@ -0,0 +1,66 @@
+// Package ghess is a chess engine. This file concerns the
+// Static Exchange Evaluation, whether a capture sequence on
+// a square wins or loses material.
+package ghess
+
+// SEE returns the material won, in centipawns, by the player
+// moving the piece on orig to dest, once every attacker and
+// defender of dest has taken back, least valuable first.
+// Either player can stop taking when it would lose material.
+// Pieces behind an attacker, such as a Rook behind a Rook, join
+// in as the pieces in front of them come off. A negative score
+// means the move loses material, and a quiet move scores 0 if
+// the piece is safe on dest. Pins are ignored.
+func (b *Board) SEE(orig, dest int) int {
+	c := *b // swap on a copy, the x-rays appear as pieces go
+	isWhite := c.isUpper(orig)
+	captured := c.board[dest]
+	if (captured == '.') && (c.board[orig] == 'P' || c.board[orig] == 'p') &&
+		(dest-orig)%10 != 0 && c.empassant != 0 {
+		// En passant
+		captured = c.board[c.empassant]
+		c.board[c.empassant] = '.'
+	}
+	gain := make([]int, 1, 32)
+	gain[0] = pieceValue(captured)
+	c.board[dest] = c.board[orig]
+	c.board[orig] = '.'
+	for {
+		isWhite = !isWhite
+		attacker := c.leastAttacker(dest, isWhite)
+		if attacker == 0 {
+			break
+		}
+		// The piece on dest is taken, minus what the other side won
+		gain = append(gain, pieceValue(c.board[dest])-gain[len(gain)-1])
+		c.board[dest] = c.board[attacker]
+		c.board[attacker] = '.'
+	}
+	// Each player picks between taking and stopping
+	for d := len(gain) - 1; d > 0; d-- {
+		if -gain[d] < gain[d-1] {
+			gain[d-1] = -gain[d]
+		}
+	}
+	return gain[0]
+}
+
+// leastAttacker returns the square of the least valuable piece
+// of isWhite attacking dest, or 0 if there is none.
+func (b *Board) leastAttacker(dest int, isWhite bool) int {
+	least, value := 0, 0
+	for idx := 11; idx < 89; idx++ {
+		val := b.board[idx]
+		if val == '.' || val == ' ' || b.isUpper(idx) != isWhite {
+			continue
+		}
+		v := pieceValue(val)
+		if least != 0 && v >= value {
+			continue
+		}
+		if b.attacks(idx, dest) {
+			least, value = idx, v
+		}
+	}
+	return least
+}
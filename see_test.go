@@ -0,0 +1,65 @@
+package ghess
+
+import (
+	"testing"
+)
+
+func TestSEE(t *testing.T) {
+	game := NewBoard()
+	// e4 takes the Knight on d5, c6 takes back
+	_ = game.LoadFen(`4k3/8/2p5/3n4/4P3/8/8/4K3 w - - 0 30`)
+	if see := game.SEE(44, 55); see != 220 {
+		t.Error("Pawn for Knight should win 220, got", see)
+	}
+	// Rook takes a pawn protected by a pawn
+	_ = game.LoadFen(`4k3/8/3p4/4p3/8/8/8/4RK2 w - - 0 30`)
+	if see := game.SEE(14, 54); see != -400 {
+		t.Error("Rook for pawn should lose 400, got", see)
+	}
+	// Knights trade
+	_ = game.LoadFen(`4k3/8/2p5/3n4/8/4N3/8/4K3 w - - 0 30`)
+	if see := game.SEE(34, 55); see != 0 {
+		t.Error("Knight trade should be even, got", see)
+	}
+	// Queen into a pawn's reach
+	_ = game.LoadFen(`4k3/8/2p5/8/8/8/8/3QK3 w - - 0 30`)
+	if see := game.SEE(15, 55); see != -900 {
+		t.Error("Queen to d5 should lose it, got", see)
+	}
+	if see := game.SEE(15, 45); see != 0 {
+		t.Error("Queen to d4 is safe, got", see)
+	}
+}
+
+func TestSEEXRay(t *testing.T) {
+	game := NewBoard()
+	// The e1 Rook backs up the e2 Rook
+	_ = game.LoadFen(`4r2k/8/8/4p3/8/8/4R3/4R2K w - - 0 30`)
+	if see := game.SEE(24, 54); see != 100 {
+		t.Error("Doubled Rooks should win the pawn, got", see)
+	}
+	_ = game.LoadFen(`4r2k/8/8/4p3/8/8/4R3/7K w - - 0 30`)
+	if see := game.SEE(24, 54); see != -400 {
+		t.Error("Single Rook should lose the exchange, got", see)
+	}
+	// Queen behind the Bishop
+	_ = game.LoadFen(`6k1/8/4b3/8/2p5/1B6/Q7/6K1 w - - 0 30`)
+	if see := game.SEE(37, 46); see != 100 {
+		t.Error("Bishop backed by Queen should win c4, got", see)
+	}
+	_ = game.LoadFen(`6k1/8/4b3/8/2p5/1B6/8/6K1 w - - 0 30`)
+	if see := game.SEE(37, 46); see != -230 {
+		t.Error("Bishop for pawn should lose, got", see)
+	}
+}
+
+func TestSEEEnPassant(t *testing.T) {
+	game := NewBoard()
+	_ = game.LoadFen(`4k3/3p4/8/4P3/8/8/8/4K3 b - - 0 30`)
+	if err := game.Move(75, 55); err != nil {
+		t.Fatal(err)
+	}
+	if see := game.SEE(54, 65); see != 100 {
+		t.Error("En passant should win a pawn, got", see)
+	}
+}
@@ -0,0 +1,469 @@
+// Package server is an HTTP server of ghess games, answering
+// in JSON. It creates games, plays moves in SAN or coordinates,
+// and asks the engine for a move or an evaluation.
+//
+// The routes are:
+//
+//	POST   /games                 create a game, {"fen": ...} is optional
+//	GET    /games/{id}            the game, with its FEN, PGN and legal moves
+//	DELETE /games/{id}            forget the game
+//	GET    /games/{id}/moves      the legal moves
+//	POST   /games/{id}/moves      play {"move": "Nf3"} or {"move": "g1f3"}
+//	POST   /games/{id}/engine     the engine plays, {"depth": 4, "movetime": 1000}
+//	GET    /games/{id}/analysis   the engine's move, ?depth=4&movetime=1000
+//
+// Errors are {"error": ...} with the status: 400 for a bad
+// request, 404 for an unknown game, 409 when the game is over
+// and 422 for an illegal move.
+package server
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/polypmer/ghess"
+)
+
+// Options are the limits of the server's engine.
+type Options struct {
+	Search   ghess.SearchOptions // of every engine search
+	Depth    int                 // when none is asked, 0 for 4
+	MaxDepth int                 // 0 for 8
+	MaxTime  time.Duration       // of a search, 0 for 10 seconds
+}
+
+// Server serves games over HTTP, see the package comment.
+type Server struct {
+	opts  Options
+	mu    sync.Mutex
+	games map[string]*game
+}
+
+// game is a game being played, locked while changed.
+type game struct {
+	sync.Mutex
+	board ghess.Board
+	fen   string   // it started from, empty for the start
+	moves []string // played, in SAN
+}
+
+// Game is the JSON of a game.
+type Game struct {
+	ID     string   `json:"id"`
+	FEN    string   `json:"fen"`
+	PGN    string   `json:"pgn"`
+	Turn   string   `json:"turn"` // white or black
+	Check  bool     `json:"check"`
+	Status string   `json:"status"` // active, checkmate, stalemate or draw
+	Result string   `json:"result"` // 1-0, 0-1, 1/2-1/2 or *
+	Moves  []string `json:"moves"`  // played, in SAN
+	Legal  []Move   `json:"legal"`
+}
+
+// Move is a move in SAN and in coordinates.
+type Move struct {
+	SAN string `json:"san"`
+	UCI string `json:"uci"`
+}
+
+// Analysis is the engine's answer for a position.
+type Analysis struct {
+	Best  Move     `json:"best"`
+	Score int      `json:"score"`          // positive for White advantage
+	Mate  int      `json:"mate,omitempty"` // moves to mate, negative if Black mates
+	Eval  int      `json:"eval"`           // static evaluation
+	Depth int      `json:"depth"`          // 0 for a book move
+	Nodes int64    `json:"nodes"`
+	PV    []string `json:"pv"`             // in SAN
+	Game  *Game    `json:"game,omitempty"` // after the engine's move
+}
+
+// New returns a Server without games.
+func New(opts Options) *Server {
+	if opts.Depth <= 0 {
+		opts.Depth = 4
+	}
+	if opts.MaxDepth <= 0 {
+		opts.MaxDepth = 8
+	}
+	if opts.MaxTime <= 0 {
+		opts.MaxTime = 10 * time.Second
+	}
+	return &Server{opts: opts, games: make(map[string]*game)}
+}
+
+// httpError is an error with its status.
+type httpError struct {
+	code int
+	msg  string
+}
+
+func (e *httpError) Error() string { return e.msg }
+
+// errorf returns an httpError.
+func errorf(code int, format string, args ...interface{}) error {
+	return &httpError{code, fmt.Sprintf(format, args...)}
+}
+
+// ServeHTTP routes a request.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	v, err := s.route(r)
+	if err != nil {
+		code := http.StatusInternalServerError
+		var he *httpError
+		if errors.As(err, &he) {
+			code = he.code
+		}
+		writeJSON(w, code, map[string]string{"error": err.Error()})
+		return
+	}
+	code := http.StatusOK
+	if r.Method == http.MethodPost && strings.Trim(r.URL.Path, "/") == "games" {
+		code = http.StatusCreated
+	}
+	if v == nil {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	writeJSON(w, code, v)
+}
+
+// writeJSON writes v as the response.
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// route answers a request with the value to write.
+func (s *Server) route(r *http.Request) (interface{}, error) {
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if parts[0] != "games" || len(parts) > 3 {
+		return nil, errorf(http.StatusNotFound, "No such route %s", r.URL.Path)
+	}
+	if len(parts) == 1 {
+		if r.Method != http.MethodPost {
+			return nil, errorf(http.StatusMethodNotAllowed, "Use POST to create a game")
+		}
+		return s.create(r)
+	}
+	id := parts[1]
+	s.mu.Lock()
+	g, ok := s.games[id]
+	s.mu.Unlock()
+	if !ok {
+		return nil, errorf(http.StatusNotFound, "No such game %q", id)
+	}
+	route := r.Method + " "
+	if len(parts) == 3 {
+		route += parts[2]
+	}
+	switch route {
+	case "GET ":
+		g.Lock()
+		defer g.Unlock()
+		return g.json(id), nil
+	case "DELETE ":
+		s.mu.Lock()
+		delete(s.games, id)
+		s.mu.Unlock()
+		return nil, nil
+	case "GET moves":
+		g.Lock()
+		defer g.Unlock()
+		return legal(&g.board), nil
+	case "POST moves":
+		var body struct {
+			Move string `json:"move"`
+		}
+		if err := decode(r, &body); err != nil {
+			return nil, err
+		}
+		return g.play(id, body.Move)
+	case "POST engine":
+		var body struct {
+			Depth    int `json:"depth"`
+			MoveTime int `json:"movetime"` // milliseconds
+		}
+		if err := decode(r, &body); err != nil {
+			return nil, err
+		}
+		return s.engineMove(r, id, g, body.Depth, body.MoveTime)
+	case "GET analysis":
+		depth, err1 := queryInt(r, "depth")
+		movetime, err2 := queryInt(r, "movetime")
+		if err1 != nil || err2 != nil {
+			return nil, errorf(http.StatusBadRequest, "depth and movetime should be numbers")
+		}
+		g.Lock()
+		board := g.board
+		g.Unlock()
+		return s.analyse(r, board, depth, movetime)
+	}
+	if len(parts) == 3 && parts[2] != "moves" && parts[2] != "engine" && parts[2] != "analysis" {
+		return nil, errorf(http.StatusNotFound, "No such route %s", r.URL.Path)
+	}
+	return nil, errorf(http.StatusMethodNotAllowed, "Method %s not allowed on %s", r.Method, r.URL.Path)
+}
+
+// decode reads the JSON body into v, an empty body
+// leaving v alone.
+func decode(r *http.Request, v interface{}) error {
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil && err != io.EOF {
+		return errorf(http.StatusBadRequest, "Invalid JSON: %v", err)
+	}
+	return nil
+}
+
+// queryInt returns a number of the query, 0 if missing.
+func queryInt(r *http.Request, key string) (int, error) {
+	value := r.URL.Query().Get(key)
+	if value == "" {
+		return 0, nil
+	}
+	return strconv.Atoi(value)
+}
+
+// create adds a game, from the start or a FEN.
+func (s *Server) create(r *http.Request) (interface{}, error) {
+	var body struct {
+		FEN string `json:"fen"`
+	}
+	if err := decode(r, &body); err != nil {
+		return nil, err
+	}
+	g := &game{board: ghess.NewBoard()}
+	if body.FEN != "" {
+		g.fen = strings.Join(strings.Fields(body.FEN), " ")
+		if err := g.board.LoadFen(g.fen); err != nil {
+			return nil, errorf(http.StatusBadRequest, "%v", err)
+		}
+	}
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	s.games[hex.EncodeToString(id)] = g
+	s.mu.Unlock()
+	return g.json(hex.EncodeToString(id)), nil
+}
+
+// play plays a move in SAN or coordinates.
+func (g *game) play(id, move string) (interface{}, error) {
+	g.Lock()
+	defer g.Unlock()
+	if status, _ := g.status(); status != "active" {
+		return nil, errorf(http.StatusConflict, "The game is over")
+	}
+	orig, dest, err := resolve(&g.board, move)
+	if err == nil {
+		err = g.move(orig, dest)
+	}
+	if err != nil {
+		return nil, errorf(http.StatusUnprocessableEntity, "Illegal move %q: %v", move, err)
+	}
+	return g.json(id), nil
+}
+
+// resolve returns the coordinates of a move in either notation.
+func resolve(b *ghess.Board, move string) (int, int, error) {
+	move = strings.TrimSpace(move)
+	if move == "" {
+		return 0, 0, errors.New("No move")
+	}
+	if orig, dest, err := b.ParseUCI(move); err == nil {
+		return orig, dest, nil
+	}
+	return b.ParseSAN(move)
+}
+
+// move plays a move, keeping its SAN.
+func (g *game) move(orig, dest int) error {
+	san := g.board.SAN(orig, dest)
+	if err := g.board.Move(orig, dest); err != nil {
+		return err
+	}
+	g.moves = append(g.moves, san)
+	return nil
+}
+
+// status returns how the game stands and its result.
+func (g *game) status() (string, string) {
+	b := &g.board
+	switch {
+	case b.Checkmate:
+		return "checkmate", b.Score
+	case b.Draw:
+		return "draw", "1/2-1/2"
+	case b.GameOver():
+		return "stalemate", "1/2-1/2"
+	}
+	return "active", "*"
+}
+
+// json returns the Game of g.
+func (g *game) json(id string) Game {
+	fen := g.board.Position()
+	turn := "white"
+	if strings.Fields(fen)[1] == "b" {
+		turn = "black"
+	}
+	status, result := g.status()
+	moves := append([]string{}, g.moves...)
+	return Game{
+		ID:     id,
+		FEN:    fen,
+		PGN:    g.pgn(result),
+		Turn:   turn,
+		Check:  g.board.Check,
+		Status: status,
+		Result: result,
+		Moves:  moves,
+		Legal:  legal(&g.board),
+	}
+}
+
+// pgn returns the game in PGN.
+func (g *game) pgn(result string) string {
+	var pgn strings.Builder
+	number, black := 1, false
+	if g.fen != "" {
+		if fields := strings.Fields(g.fen); len(fields) == 6 {
+			number, _ = strconv.Atoi(fields[5])
+			black = fields[1] == "b"
+		}
+		pgn.WriteString("[SetUp \"1\"]\n[FEN \"" + g.fen + "\"]\n")
+	}
+	pgn.WriteString("[Result \"" + result + "\"]\n\n")
+	for i, san := range g.moves {
+		if !black {
+			pgn.WriteString(strconv.Itoa(number) + ". ")
+		} else if i == 0 {
+			pgn.WriteString(strconv.Itoa(number) + "... ")
+		}
+		pgn.WriteString(san + " ")
+		if black {
+			number++
+		}
+		black = !black
+	}
+	pgn.WriteString(result)
+	return pgn.String()
+}
+
+// legal returns the valid moves of the board.
+func legal(b *ghess.Board) []Move {
+	origs, dests := b.SearchValid()
+	moves := make([]Move, 0, len(origs))
+	for i := range origs {
+		moves = append(moves, Move{b.SAN(origs[i], dests[i]), b.UCIMove(origs[i], dests[i])})
+	}
+	return moves
+}
+
+// engineMove plays the engine's move in the game.
+func (s *Server) engineMove(r *http.Request, id string, g *game, depth, movetime int) (interface{}, error) {
+	g.Lock()
+	board, played := g.board, len(g.moves)
+	g.Unlock()
+	analysis, err := s.analyse(r, board, depth, movetime)
+	if err != nil {
+		return nil, err
+	}
+	g.Lock()
+	defer g.Unlock()
+	if len(g.moves) != played {
+		return nil, errorf(http.StatusConflict, "The game changed during the search")
+	}
+	orig, dest, err := g.board.ParseUCI(analysis.Best.UCI)
+	if err == nil {
+		err = g.move(orig, dest)
+	}
+	if err != nil {
+		return nil, err
+	}
+	game := g.json(id)
+	analysis.Game = &game
+	return analysis, nil
+}
+
+// analyse searches the board within the limits, movetime
+// being in milliseconds.
+func (s *Server) analyse(r *http.Request, board ghess.Board, depth, movetime int) (*Analysis, error) {
+	if depth < 0 || movetime < 0 {
+		return nil, errorf(http.StatusBadRequest, "depth and movetime can't be negative")
+	}
+	if depth == 0 {
+		depth = s.opts.Depth
+	}
+	if depth > s.opts.MaxDepth {
+		depth = s.opts.MaxDepth
+	}
+	limit := time.Duration(movetime) * time.Millisecond
+	if limit == 0 || limit > s.opts.MaxTime {
+		limit = s.opts.MaxTime
+	}
+	origs, dests := board.SearchValid()
+	if len(origs) == 0 || board.Checkmate || board.Draw {
+		return nil, errorf(http.StatusConflict, "The game is over")
+	}
+
+	// Stop at the limit, or when the client goes away
+	stop, done := make(chan struct{}), make(chan struct{})
+	defer close(done)
+	go func() {
+		timer := time.NewTimer(limit)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-r.Context().Done():
+		case <-done:
+		}
+		close(stop)
+	}()
+	var info ghess.SearchInfo
+	opts := s.opts.Search
+	opts.Stop = stop
+	opts.Info = func(i ghess.SearchInfo) {
+		if i.Complete { // not of a stopped deeper iteration
+			info = i
+		}
+	}
+	state := ghess.GetState(&board)
+	state.SetOptions(opts)
+	best, err := ghess.IterativeDeepening(depth, state)
+
+	analysis := &Analysis{Eval: board.Evaluate(), PV: []string{}}
+	orig, dest := origs[0], dests[0] // stopped before depth 1
+	if err == nil && best.Init != [2]int{} {
+		orig, dest = best.Init[0], best.Init[1]
+		analysis.Score, analysis.Mate = info.Score, info.Mate
+		analysis.Depth, analysis.Nodes = info.Depth, info.Nodes
+	}
+	analysis.Best = Move{board.SAN(orig, dest), board.UCIMove(orig, dest)}
+	if analysis.Depth == 0 {
+		analysis.Score = analysis.Eval
+	}
+	pv := info.PV
+	if len(pv) == 0 || pv[0] != [2]int{orig, dest} {
+		pv = [][2]int{{orig, dest}} // the info is of another move
+	}
+	c := board
+	for _, move := range pv {
+		analysis.PV = append(analysis.PV, c.SAN(move[0], move[1]))
+		if c.Move(move[0], move[1]) != nil {
+			break
+		}
+	}
+	return analysis, nil
+}
@@ -0,0 +1,104 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/polypmer/ghess"
+)
+
+// do sends a request and decodes the answer into v.
+func do(t *testing.T, h http.Handler, method, path, body string, v interface{}) int {
+	t.Helper()
+	r := httptest.NewRequest(method, path, strings.NewReader(body))
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if v != nil && w.Body.Len() > 0 {
+		if err := json.Unmarshal(w.Body.Bytes(), v); err != nil {
+			t.Fatal(path, err, w.Body.String())
+		}
+	}
+	return w.Code
+}
+
+func TestGame(t *testing.T) {
+	s := New(Options{Search: ghess.DefaultOptions()})
+	var game Game
+	if code := do(t, s, "POST", "/games", "", &game); code != http.StatusCreated {
+		t.Fatal("Create should be 201", code)
+	}
+	if len(game.Legal) != 20 || game.Turn != "white" || game.Status != "active" {
+		t.Error("Wrong new game", game)
+	}
+	path := "/games/" + game.ID
+	if code := do(t, s, "POST", path+"/moves", `{"move": "e4"}`, &game); code != http.StatusOK {
+		t.Fatal("SAN move should be played", code)
+	}
+	if code := do(t, s, "POST", path+"/moves", `{"move": "e7e5"}`, &game); code != http.StatusOK {
+		t.Fatal("Coordinate move should be played", code)
+	}
+	var e struct{ Error string }
+	if code := do(t, s, "POST", path+"/moves", `{"move": "e5"}`, &e); code != http.StatusUnprocessableEntity || e.Error == "" {
+		t.Error("Illegal move should be 422", code, e)
+	}
+	if code := do(t, s, "POST", path+"/moves", `{"move": "d7d5"}`, &e); code != http.StatusUnprocessableEntity {
+		t.Error("Moving Black's pawn for White should be 422", code)
+	}
+	if code := do(t, s, "POST", path+"/moves", `{"move":`, &e); code != http.StatusBadRequest {
+		t.Error("Bad JSON should be 400", code)
+	}
+	do(t, s, "GET", path, "", &game)
+	if game.PGN != "[Result \"*\"]\n\n1. e4 e5 *" || strings.Join(game.Moves, " ") != "e4 e5" {
+		t.Error("Wrong PGN", game.PGN)
+	}
+	if !strings.HasPrefix(game.FEN, "rnbqkbnr/pppp1ppp/8/4p3/4P3/8/PPPP1PPP/RNBQKBNR w") {
+		t.Error("Wrong FEN", game.FEN)
+	}
+
+	var analysis Analysis
+	if code := do(t, s, "POST", path+"/engine", `{"depth": 2}`, &analysis); code != http.StatusOK {
+		t.Fatal("Engine should move", code)
+	}
+	if analysis.Game == nil || len(analysis.Game.Moves) != 3 || analysis.Game.Moves[2] != analysis.Best.SAN {
+		t.Error("Engine move should be played", analysis)
+	}
+
+	if code := do(t, s, "DELETE", path, "", nil); code != http.StatusNoContent {
+		t.Error("Delete should be 204", code)
+	}
+	if code := do(t, s, "GET", path, "", &e); code != http.StatusNotFound {
+		t.Error("Deleted game should be 404", code)
+	}
+}
+
+func TestAnalysis(t *testing.T) {
+	s := New(Options{})
+	var game Game
+	do(t, s, "POST", "/games", `{"fen": "6k1/5ppp/8/8/8/8/5PPP/3R2K1 w - - 12 30"}`, &game)
+	path := "/games/" + game.ID
+	var analysis Analysis
+	if code := do(t, s, "GET", path+"/analysis?depth=2", "", &analysis); code != http.StatusOK {
+		t.Fatal("Analysis should be 200", code)
+	}
+	if analysis.Best.SAN != "Rd8#" || analysis.Best.UCI != "d1d8" || analysis.Mate != 1 {
+		t.Error("Should find mate in 1", analysis)
+	}
+	if code := do(t, s, "GET", path+"/analysis?depth=two", "", nil); code != http.StatusBadRequest {
+		t.Error("Bad depth should be 400", code)
+	}
+	if code := do(t, s, "POST", path+"/moves", `{"move": "Rd8+"}`, &game); code != http.StatusOK {
+		t.Fatal("Mate should be played", code)
+	}
+	if game.Status != "checkmate" || game.Result != "1-0" || !strings.HasSuffix(game.PGN, "30. Rd8# 1-0") {
+		t.Error("Game should be over", game)
+	}
+	if code := do(t, s, "POST", path+"/engine", "", nil); code != http.StatusConflict {
+		t.Error("Engine move after mate should be 409", code)
+	}
+	if code := do(t, s, "POST", "/games", `{"fen": "nonsense"}`, nil); code != http.StatusBadRequest {
+		t.Error("Bad FEN should be 400", code)
+	}
+}
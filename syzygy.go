@@ -0,0 +1,354 @@
+// Package ghess is a chess engine. This file concerns Syzygy
+// endgame tablebase files, read from a local directory.
+//
+// The tables are found by their material signature, and read
+// into memory the first time they are probed. WDL tables don't
+// store positions where a capture is best, so probing searches
+// the captures first, down to the smaller tables. The Board only
+// promotes to a Queen, so underpromotions aren't searched.
+package ghess
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Syzygy file extensions and the magic bytes they start with.
+const (
+	syzygyWDLExt = ".rtbw"
+	syzygyDTZExt = ".rtbz"
+)
+
+var (
+	syzygyWDLMagic = []byte{0x71, 0xE8, 0x23, 0x5D}
+	syzygyDTZMagic = []byte{0xD7, 0x66, 0x0C, 0xA5}
+
+	// ErrNoTable is returned when probing material without a table.
+	ErrNoTable = errors.New("No tablebase for this material")
+	// ErrSyzygyDecode is returned when probing a table which
+	// isn't a Syzygy table, or is damaged.
+	ErrSyzygyDecode = errors.New("Can't decode the Syzygy table")
+
+	// errSyzygySide is returned probing a DTZ table which
+	// stores the other side to move.
+	errSyzygySide = errors.New("The DTZ table is of the other side to move")
+)
+
+// Syzygy is a directory of Syzygy tablebase files, it
+// implements Tablebase. Files are named by their material,
+// the stronger side first, such as KRPvKR.rtbw.
+type Syzygy struct {
+	dir       string
+	wdl, dtz  map[string]string // file paths by material signature
+	maxPieces int
+
+	mu     sync.Mutex
+	tables map[string]*syzygyTable // read so far by path, nil if damaged
+}
+
+// OpenSyzygy finds the Syzygy files in dir, checking that
+// each starts with the magic bytes of its kind.
+func OpenSyzygy(dir string) (*Syzygy, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	tb := &Syzygy{
+		dir:    dir,
+		wdl:    make(map[string]string),
+		dtz:    make(map[string]string),
+		tables: make(map[string]*syzygyTable),
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		ext := filepath.Ext(name)
+		if entry.IsDir() || (ext != syzygyWDLExt && ext != syzygyDTZExt) {
+			continue
+		}
+		signature := strings.TrimSuffix(name, ext)
+		if !validSignature(signature) {
+			return nil, fmt.Errorf("Syzygy file %s isn't named by material", name)
+		}
+		path := filepath.Join(dir, name)
+		magic, tables := syzygyWDLMagic, tb.wdl
+		if ext == syzygyDTZExt {
+			magic, tables = syzygyDTZMagic, tb.dtz
+		}
+		if err := checkMagic(path, magic); err != nil {
+			return nil, err
+		}
+		tables[signature] = path
+		if pieces := len(signature) - 1; pieces > tb.maxPieces {
+			tb.maxPieces = pieces
+		}
+	}
+	return tb, nil
+}
+
+// validSignature returns true for material such as KQvK.
+func validSignature(signature string) bool {
+	sides := strings.Split(signature, "v")
+	if len(sides) != 2 {
+		return false
+	}
+	for _, side := range sides {
+		if !strings.HasPrefix(side, "K") || strings.Trim(side, "KQRBNP") != "" ||
+			strings.Count(side, "K") != 1 {
+			return false
+		}
+	}
+	return true
+}
+
+// checkMagic returns an error if the file at path
+// doesn't start with magic.
+func checkMagic(path string, magic []byte) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	head := make([]byte, len(magic))
+	if _, err := io.ReadFull(f, head); err != nil || !bytes.Equal(head, magic) {
+		return fmt.Errorf("%s isn't a Syzygy file", path)
+	}
+	return nil
+}
+
+// MaxPieces is the most pieces of any table found.
+func (tb *Syzygy) MaxPieces() int {
+	return tb.maxPieces
+}
+
+// Tables returns the material signatures which have
+// a WDL table, in order.
+func (tb *Syzygy) Tables() []string {
+	tables := make([]string, 0, len(tb.wdl))
+	for signature := range tb.wdl {
+		tables = append(tables, signature)
+	}
+	sort.Strings(tables)
+	return tables
+}
+
+// syzygyFile returns the path and signature of the table for
+// the material on the board, which is named with either side first.
+func (b *Board) syzygyFile(tables map[string]string) (string, string, error) {
+	counts := b.pieceCounts()
+	signature := materialSignature(&counts)
+	if path, ok := tables[signature]; ok {
+		return path, signature, nil
+	}
+	sides := strings.Split(signature, "v")
+	signature = sides[1] + "v" + sides[0]
+	if path, ok := tables[signature]; ok {
+		return path, signature, nil
+	}
+	return "", "", ErrNoTable
+}
+
+// table returns the WDL or DTZ table for the board,
+// reading it the first time.
+func (tb *Syzygy) table(b *Board, dtz bool) (*syzygyTable, error) {
+	files := tb.wdl
+	if dtz {
+		files = tb.dtz
+	}
+	path, signature, err := b.syzygyFile(files)
+	if err != nil {
+		return nil, err
+	}
+	tb.mu.Lock()
+	defer tb.mu.Unlock()
+	if t, ok := tb.tables[path]; ok {
+		if t == nil {
+			return nil, ErrSyzygyDecode
+		}
+		return t, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	t, err := readSyzygyTable(signature, data, dtz)
+	tb.tables[path] = t
+	return t, err
+}
+
+// onlyKings is true if the board has nothing but the Kings.
+func (b *Board) onlyKings() bool {
+	for idx := 11; idx < 89; idx++ {
+		if val := b.board[idx]; val != '.' && val != ' ' && val != 'K' && val != 'k' {
+			return false
+		}
+	}
+	return true
+}
+
+// probeWDL returns the WDL stored in the table for the board.
+func (tb *Syzygy) probeWDL(b *Board) (WDL, error) {
+	if b.onlyKings() {
+		return Draw, nil
+	}
+	t, err := tb.table(b, false)
+	if err != nil {
+		return Draw, err
+	}
+	value, _, err := t.probe(b)
+	if err != nil {
+		return Draw, err
+	}
+	return WDL(value - 2), nil
+}
+
+// syzygyMove is a legal move of the board, and whether it zeroes
+// the fifty move count, a capture or a pawn move.
+type syzygyMove struct {
+	orig, dest    int
+	capture, pawn bool
+}
+
+// syzygyMoves returns the legal moves of the board.
+func (b *Board) syzygyMoves() []syzygyMove {
+	origs, dests := b.SearchValid()
+	moves := make([]syzygyMove, len(origs))
+	for i := range origs {
+		moves[i] = syzygyMove{orig: origs[i], dest: dests[i]}
+		moves[i].capture, moves[i].pawn = b.zeroing(origs[i], dests[i])
+	}
+	return moves
+}
+
+// search returns the WDL of the board, searching the captures,
+// and the pawn moves too if pawnMoves, before the table. It also
+// returns true if the best move is a capture or pawn move, when
+// the DTZ table can't be trusted.
+func (tb *Syzygy) search(b *Board, pawnMoves bool) (WDL, bool, error) {
+	moves := b.syzygyMoves()
+	best, searched := Loss, 0
+	for _, move := range moves {
+		if !move.capture && (!pawnMoves || !move.pawn) {
+			continue
+		}
+		searched++
+		child := *b
+		if err := child.Move(move.orig, move.dest); err != nil {
+			return Draw, false, err
+		}
+		wdl, _, err := tb.search(&child, false)
+		if err != nil {
+			return Draw, false, err
+		}
+		if -wdl > best {
+			best = -wdl
+			if best == Win {
+				return Win, true, nil
+			}
+		}
+	}
+
+	// With every move searched, the table isn't needed
+	all := searched > 0 && searched == len(moves)
+	wdl := best
+	if !all {
+		var err error
+		if wdl, err = tb.probeWDL(b); err != nil {
+			return Draw, false, err
+		}
+	}
+	if best >= wdl {
+		return best, best > Draw || all, nil
+	}
+	return wdl, false, nil
+}
+
+// ProbeWDL returns the result for the player to move.
+func (tb *Syzygy) ProbeWDL(b *Board) (WDL, error) {
+	wdl, _, err := tb.search(b, false)
+	return wdl, err
+}
+
+// ProbeDTZ returns the distance to zero for the player to move:
+// the plies to the next capture or pawn move, with the sign of
+// the WDL, counting 100 more for cursed wins and blessed losses.
+// The tables keep one side to move, so the other side is found
+// by looking a move ahead.
+func (tb *Syzygy) ProbeDTZ(b *Board) (int, error) {
+	wdl, zeroing, err := tb.search(b, true)
+	if err != nil || wdl == Draw {
+		return 0, err
+	}
+	if zeroing {
+		return dtzBeforeZeroing(wdl), nil
+	}
+	dtz, err := tb.probeDTZ(b, wdl)
+	if err == nil {
+		if wdl == CursedWin || wdl == BlessedLoss {
+			dtz += 100
+		}
+		if wdl < Draw {
+			dtz = -dtz
+		}
+		return dtz, nil
+	}
+	if err != errSyzygySide {
+		return 0, err
+	}
+
+	best := 0xFFFF
+	for _, move := range b.syzygyMoves() {
+		child := *b
+		if err := child.Move(move.orig, move.dest); err != nil {
+			return 0, err
+		}
+		if move.capture || move.pawn {
+			// The distance is of the move before zeroing
+			childWDL, _, err := tb.search(&child, false)
+			if err != nil {
+				return 0, err
+			}
+			dtz = -dtzBeforeZeroing(childWDL)
+		} else {
+			if dtz, err = tb.ProbeDTZ(&child); err != nil {
+				return 0, err
+			}
+			dtz = -dtz
+			if dtz == 1 && child.Checkmate {
+				best = 1
+			}
+			if dtz > 0 {
+				dtz++
+			} else if dtz < 0 {
+				dtz--
+			}
+		}
+		if dtz < best && (dtz > 0) == (wdl > Draw) && dtz != 0 {
+			best = dtz
+		}
+	}
+	if best == 0xFFFF {
+		return -1, nil // mated
+	}
+	return best, nil
+}
+
+// probeDTZ returns the DTZ stored in the table for the board,
+// without the sign, or errSyzygySide.
+func (tb *Syzygy) probeDTZ(b *Board, wdl WDL) (int, error) {
+	t, err := tb.table(b, true)
+	if err != nil {
+		return 0, err
+	}
+	value, d, err := t.probe(b)
+	if err != nil {
+		return 0, err
+	}
+	return t.dtzPlies(d, value, wdl)
+}
@@ -0,0 +1,835 @@
+// Package ghess is a chess engine. This file concerns decoding
+// Syzygy table files: finding the index of a position in a table,
+// and the value stored at that index in the compressed data.
+//
+// Squares are 0 - 63, a1 to h1 then up the ranks, and pieces are
+// 1 - 6 for the White PNBRQK and 9 - 14 for the Black, as they
+// are in the files. The encoding follows Ronald de Man's probing
+// code, which the tables were generated with.
+package ghess
+
+import (
+	"bytes"
+	"sort"
+	"strings"
+)
+
+// syzygyMaxPieces is the most pieces of a Syzygy table.
+const syzygyMaxPieces = 7
+
+// Flags of the pairs data of a table.
+const (
+	syzygySTM         = 1   // side to move of a DTZ table, 1 for Black
+	syzygyMapped      = 2   // DTZ values are looked up in a map
+	syzygyWinPlies    = 4   // DTZ of wins are in plies, not moves
+	syzygyLossPlies   = 8   // DTZ of losses are in plies
+	syzygyWide        = 16  // the map is of 16 bit values
+	syzygySingleValue = 128 // every position has the same value
+)
+
+// syzygyWDLMap is which of the four DTZ maps is for a WDL,
+// from Loss to Win.
+var syzygyWDLMap = [5]int{1, 3, 0, 2, 0}
+
+// Tables for the index of a position, filled by init.
+var (
+	syzygyBinomial      [syzygyMaxPieces][64]uint64 // ways to put k pieces on n squares
+	syzygyPawnMap       [64]int                     // a2 to h7, 47 down to 0, the edges first
+	syzygyLeadPawnIdx   [syzygyMaxPieces][64]uint64 // by leading pawns and square
+	syzygyLeadPawnsSize [syzygyMaxPieces][4]uint64  // by leading pawns and file
+	syzygyB1H1H7        [64]int                     // squares below the a1-h8 diagonal to 0 - 27
+	syzygyA1D1D4        [64]int                     // the a1-d1-d4 triangle to 0 - 9
+	syzygyKK            [10][64]int                 // Kings, the first in the triangle, to 0 - 461
+)
+
+func init() {
+	code := 0
+	for sq := 0; sq < 64; sq++ {
+		if syzygyDiagonal(sq) < 0 {
+			syzygyB1H1H7[sq] = code
+			code++
+		}
+	}
+
+	// The triangle below the diagonal, then the diagonal
+	code = 0
+	var diagonal []int
+	for sq := 0; sq <= 27; sq++ {
+		if sq%8 > 3 {
+			continue
+		}
+		if syzygyDiagonal(sq) < 0 {
+			syzygyA1D1D4[sq] = code
+			code++
+		} else if syzygyDiagonal(sq) == 0 {
+			diagonal = append(diagonal, sq)
+		}
+	}
+	for _, sq := range diagonal {
+		syzygyA1D1D4[sq] = code
+		code++
+	}
+
+	// With the first King on the diagonal, the second isn't above
+	// it, and with both on it they come last
+	code = 0
+	var both [][2]int
+	for idx := 0; idx < 10; idx++ {
+		for k1 := 0; k1 <= 27; k1++ {
+			if syzygyA1D1D4[k1] != idx || (idx == 0 && k1 != 1) {
+				continue // only b1 is 0
+			}
+			for k2 := 0; k2 < 64; k2++ {
+				switch {
+				case kpkDistance(k1, k2) <= 1:
+				case syzygyDiagonal(k1) == 0 && syzygyDiagonal(k2) > 0:
+				case syzygyDiagonal(k1) == 0 && syzygyDiagonal(k2) == 0:
+					both = append(both, [2]int{idx, k2})
+				default:
+					syzygyKK[idx][k2] = code
+					code++
+				}
+			}
+		}
+	}
+	for _, kings := range both {
+		syzygyKK[kings[0]][kings[1]] = code
+		code++
+	}
+
+	syzygyBinomial[0][0] = 1
+	for n := 1; n < 64; n++ {
+		for k := 0; k < syzygyMaxPieces && k <= n; k++ {
+			if k > 0 {
+				syzygyBinomial[k][n] += syzygyBinomial[k-1][n-1]
+			}
+			if k < n {
+				syzygyBinomial[k][n] += syzygyBinomial[k][n-1]
+			}
+		}
+	}
+
+	// The leading pawn is the one with the highest syzygyPawnMap,
+	// and the others are on the squares below it
+	available := 47
+	for count := 1; count < syzygyMaxPieces; count++ {
+		for file := 0; file < 4; file++ {
+			var idx uint64
+			for rank := 1; rank <= 6; rank++ {
+				sq := rank*8 + file
+				if count == 1 {
+					syzygyPawnMap[sq] = available
+					syzygyPawnMap[sq^7] = available - 1
+					available -= 2
+				}
+				syzygyLeadPawnIdx[count][sq] = idx
+				idx += syzygyBinomial[count-1][syzygyPawnMap[sq]]
+			}
+			syzygyLeadPawnsSize[count][file] = idx
+		}
+	}
+}
+
+// syzygyDiagonal is negative below the a1-h8 diagonal,
+// 0 on it and positive above it.
+func syzygyDiagonal(sq int) int {
+	return sq/8 - sq%8
+}
+
+// syzygySquare turns a Board coordinate into a square.
+func syzygySquare(idx int) int {
+	return (idx/10-1)*8 + 8 - idx%10
+}
+
+// syzygyPiece returns the piece of a Board value, or 0.
+func syzygyPiece(val byte) int {
+	if i := strings.IndexByte("PNBRQK", val); i >= 0 {
+		return i + 1
+	}
+	if i := strings.IndexByte("pnbrqk", val); i >= 0 {
+		return i + 9
+	}
+	return 0
+}
+
+// syzygyTable is a decoded table file, kept in memory.
+type syzygyTable struct {
+	signature string // the material, as the file is named
+	data      []byte
+	dtz       bool
+	pieces    int
+	symmetric bool // both sides have the same material
+	hasPawns  bool
+	bothPawns bool              // both sides have pawns
+	unique    bool              // a side has a single piece of a kind
+	sides     int               // the pairs data stored by side to move
+	files     int               // and by file of the leading pawn
+	pairs     [2][4]syzygyPairs // by side to move and file
+	mapStart  int               // of the DTZ maps
+}
+
+// syzygyPairs is the compressed values of a table, for one
+// side to move and, with pawns, one file of the leading pawn.
+type syzygyPairs struct {
+	flags    int
+	pieces   [syzygyMaxPieces]int     // in the order they are encoded
+	groupLen [syzygyMaxPieces + 1]int // of the groups of pieces, 0 ended
+	groupIdx [syzygyMaxPieces + 1]uint64
+	value    int // of a single value table
+
+	blockSize    uint64 // bytes in a block
+	span         uint64 // values between sparse index entries
+	blocks       int
+	sparse       int // offset of the sparse index
+	sparseSize   int
+	blockLen     int // offset of the block lengths
+	blockLenSize int
+	start        int // offset of the first block
+
+	minSymLen   int
+	lowestSym   []int    // by symbol length
+	base64      []uint64 // the lowest code of a length, left aligned
+	left, right []int    // a pair of symbols, or the value of a leaf
+	symLen      []int    // values of a symbol, less one
+	mapIdx      [4]int   // DTZ maps, see syzygyWDLMap
+}
+
+// syzygyReader reads a table file, noting reads past its end.
+type syzygyReader struct {
+	data  []byte
+	pos   int
+	short bool
+}
+
+func (r *syzygyReader) byte() int {
+	if r.pos >= len(r.data) {
+		r.short = true
+		r.pos++
+		return 0
+	}
+	r.pos++
+	return int(r.data[r.pos-1])
+}
+
+func (r *syzygyReader) uint16() int {
+	return r.byte() | r.byte()<<8
+}
+
+func (r *syzygyReader) uint32() int {
+	return r.uint16() | r.uint16()<<16
+}
+
+// newSyzygyTable returns a table of the material signature,
+// such as KRPvKR, before its file is read.
+func newSyzygyTable(signature string, dtz bool) *syzygyTable {
+	sides := strings.Split(signature, "v")
+	t := &syzygyTable{
+		signature: signature,
+		dtz:       dtz,
+		pieces:    len(signature) - 1,
+		symmetric: sides[0] == sides[1],
+		hasPawns:  strings.Contains(signature, "P"),
+		bothPawns: strings.Contains(sides[0], "P") && strings.Contains(sides[1], "P"),
+		sides:     1,
+		files:     1,
+	}
+	for _, side := range sides {
+		for _, piece := range "PNBRQ" {
+			if strings.Count(side, string(piece)) == 1 {
+				t.unique = true
+			}
+		}
+	}
+	if !dtz && !t.symmetric {
+		t.sides = 2
+	}
+	if t.hasPawns {
+		t.files = 4
+	}
+	return t
+}
+
+// readSyzygyTable decodes the header of a table file
+// for the material signature.
+func readSyzygyTable(signature string, data []byte, dtz bool) (*syzygyTable, error) {
+	magic := syzygyWDLMagic
+	if dtz {
+		magic = syzygyDTZMagic
+	}
+	if !validSignature(signature) || len(signature)-1 > syzygyMaxPieces ||
+		len(data) < len(magic) || !bytes.Equal(data[:len(magic)], magic) {
+		return nil, ErrSyzygyDecode
+	}
+	t := newSyzygyTable(signature, dtz)
+	t.data = data
+	files := t.files
+
+	r := &syzygyReader{data: data, pos: len(magic)}
+	flags := r.byte()
+	if (flags&2 != 0) != t.hasPawns || !dtz && (flags&1 != 0) == t.symmetric {
+		return nil, ErrSyzygyDecode
+	}
+	for f := 0; f < files; f++ {
+		orders := r.byte()
+		pawnOrders := 0xFF
+		if t.bothPawns {
+			pawnOrders = r.byte()
+		}
+		for k := 0; k < t.pieces; k++ {
+			pieces := r.byte()
+			t.pairs[0][f].pieces[k] = pieces & 0xF
+			t.pairs[1][f].pieces[k] = pieces >> 4
+		}
+		for i := 0; i < t.sides; i++ {
+			order := [2]int{orders & 0xF, pawnOrders & 0xF}
+			if i == 1 {
+				order = [2]int{orders >> 4, pawnOrders >> 4}
+			}
+			if err := t.groups(&t.pairs[i][f], order, f); err != nil {
+				return nil, err
+			}
+		}
+	}
+	r.pos += r.pos & 1
+
+	for f := 0; f < files; f++ {
+		for i := 0; i < t.sides; i++ {
+			if err := t.pairs[i][f].sizes(r); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if dtz {
+		t.maps(r, files)
+	}
+	for f := 0; f < files; f++ {
+		for i := 0; i < t.sides; i++ {
+			t.pairs[i][f].sparse = r.pos
+			r.pos += t.pairs[i][f].sparseSize * 6
+		}
+	}
+	for f := 0; f < files; f++ {
+		for i := 0; i < t.sides; i++ {
+			t.pairs[i][f].blockLen = r.pos
+			r.pos += t.pairs[i][f].blockLenSize * 2
+		}
+	}
+	for f := 0; f < files; f++ {
+		for i := 0; i < t.sides; i++ {
+			r.pos = (r.pos + 0x3F) &^ 0x3F
+			d := &t.pairs[i][f]
+			d.start = r.pos
+			r.pos += d.blocks * int(d.blockSize)
+		}
+	}
+	if r.short || r.pos > len(data) {
+		return nil, ErrSyzygyDecode
+	}
+	return t, nil
+}
+
+// groups sets the groups of pieces which are encoded together,
+// the leading pawns or pieces first, then the others of a kind.
+// A group's index is multiplied by the ways to place the groups
+// encoded after it, in the order of the table.
+func (t *syzygyTable) groups(d *syzygyPairs, order [2]int, file int) error {
+	// The pieces are those of the signature
+	var counts [16]int
+	for i, side := range strings.Split(t.signature, "v") {
+		for _, piece := range side {
+			counts[strings.IndexRune("PNBRQK", piece)+1+8*i]++
+		}
+	}
+	for _, piece := range d.pieces[:t.pieces] {
+		counts[piece]--
+	}
+	for _, n := range counts {
+		if n != 0 {
+			return ErrSyzygyDecode
+		}
+	}
+	if t.hasPawns && d.pieces[0]&7 != 1 {
+		return ErrSyzygyDecode
+	}
+	first := 2 // the Kings
+	if t.hasPawns {
+		first = 0
+	} else if t.unique {
+		first = 3
+	}
+	n := 0
+	d.groupLen[0] = 1
+	for i := 1; i < t.pieces; i++ {
+		first--
+		if first > 0 || d.pieces[i] == d.pieces[i-1] {
+			d.groupLen[n]++
+		} else {
+			n++
+			d.groupLen[n] = 1
+		}
+	}
+	n++
+	d.groupLen[n] = 0
+
+	next := 1
+	if t.bothPawns {
+		next = 2
+	}
+	free := 64 - d.groupLen[0]
+	if t.bothPawns {
+		free -= d.groupLen[1]
+	}
+	idx := uint64(1)
+	for k := 0; next < n || k == order[0] || k == order[1]; k++ {
+		switch {
+		case k == order[0]:
+			d.groupIdx[0] = idx
+			switch {
+			case t.hasPawns:
+				idx *= syzygyLeadPawnsSize[d.groupLen[0]][file]
+			case t.unique:
+				idx *= 31332
+			default:
+				idx *= 462
+			}
+		case k == order[1]:
+			d.groupIdx[1] = idx
+			idx *= syzygyBinomial[d.groupLen[1]][48-d.groupLen[0]]
+		default:
+			d.groupIdx[next] = idx
+			idx *= syzygyBinomial[d.groupLen[next]][free]
+			free -= d.groupLen[next]
+			next++
+		}
+	}
+	d.groupIdx[n] = idx
+	if d.groupIdx[0] == 0 {
+		return ErrSyzygyDecode
+	}
+	return nil
+}
+
+// sizes reads the sizes of the compressed data, and the
+// symbols of the canonical Huffman code.
+func (d *syzygyPairs) sizes(r *syzygyReader) error {
+	d.flags = r.byte()
+	if d.flags&syzygySingleValue != 0 {
+		d.value = r.byte()
+		return nil
+	}
+	blockSize, span := r.byte(), r.byte()
+	if blockSize > 30 || span > 30 {
+		return ErrSyzygyDecode
+	}
+	d.blockSize, d.span = 1<<uint(blockSize), 1<<uint(span)
+	d.sparseSize = int((d.size() + d.span - 1) / d.span)
+	padding := r.byte()
+	d.blocks = r.uint32()
+	d.blockLenSize = d.blocks + padding
+	maxSymLen := r.byte()
+	d.minSymLen = r.byte()
+	if d.minSymLen < 1 || maxSymLen < d.minSymLen || maxSymLen > 32 {
+		return ErrSyzygyDecode
+	}
+	lengths := maxSymLen - d.minSymLen + 1
+	d.lowestSym = make([]int, lengths)
+	for i := range d.lowestSym {
+		d.lowestSym[i] = r.uint16()
+	}
+
+	// Longer codes are lower, so base64[i] >= base64[i+1]
+	d.base64 = make([]uint64, lengths)
+	for i := lengths - 2; i >= 0; i-- {
+		d.base64[i] = (d.base64[i+1] + uint64(d.lowestSym[i]) - uint64(d.lowestSym[i+1])) / 2
+	}
+	for i := range d.base64 {
+		d.base64[i] <<= uint(64 - i - d.minSymLen)
+	}
+
+	// Each symbol is a value, or a pair of symbols
+	symbols := r.uint16()
+	d.left, d.right = make([]int, symbols), make([]int, symbols)
+	for s := 0; s < symbols; s++ {
+		a, b, c := r.byte(), r.byte(), r.byte()
+		d.left[s], d.right[s] = (b&0xF)<<8|a, c<<4|b>>4
+		if d.right[s] != 0xFFF && (d.left[s] >= symbols || d.right[s] >= symbols) {
+			return ErrSyzygyDecode
+		}
+	}
+	r.pos += symbols & 1
+	d.symLen = make([]int, symbols)
+	visited := make([]bool, symbols)
+	for s := 0; s < symbols; s++ {
+		if !visited[s] {
+			d.symLen[s] = d.setSymLen(s, visited)
+		}
+	}
+	return nil
+}
+
+// size is the number of values of the pairs data.
+func (d *syzygyPairs) size() uint64 {
+	n := 0
+	for d.groupLen[n] != 0 {
+		n++
+	}
+	return d.groupIdx[n]
+}
+
+// setSymLen returns the values of a symbol less one.
+func (d *syzygyPairs) setSymLen(s int, visited []bool) int {
+	visited[s] = true
+	if d.right[s] == 0xFFF {
+		return 0
+	}
+	left, right := d.left[s], d.right[s]
+	if !visited[left] {
+		d.symLen[left] = d.setSymLen(left, visited)
+	}
+	if !visited[right] {
+		d.symLen[right] = d.setSymLen(right, visited)
+	}
+	return d.symLen[left] + d.symLen[right] + 1
+}
+
+// maps reads where the maps of DTZ values are, for each
+// WDL, of the files whose values are mapped.
+func (t *syzygyTable) maps(r *syzygyReader, files int) {
+	t.mapStart = r.pos
+	for f := 0; f < files; f++ {
+		d := &t.pairs[0][f]
+		if d.flags&syzygyMapped == 0 {
+			continue
+		}
+		if d.flags&syzygyWide != 0 {
+			r.pos += r.pos & 1
+			for i := 0; i < 4; i++ {
+				d.mapIdx[i] = (r.pos-t.mapStart)/2 + 1
+				n := r.uint16()
+				r.pos += 2 * n
+			}
+		} else {
+			for i := 0; i < 4; i++ {
+				d.mapIdx[i] = r.pos - t.mapStart + 1
+				n := r.byte()
+				r.pos += n
+			}
+		}
+	}
+	r.pos += r.pos & 1
+}
+
+// probe returns the value stored for the position, with
+// the pairs data it was read from.
+func (t *syzygyTable) probe(b *Board) (int, *syzygyPairs, error) {
+	d, idx, err := t.boardIndex(b)
+	if err != nil {
+		return 0, nil, err
+	}
+	value, err := t.decompress(d, idx)
+	return value, d, err
+}
+
+// boardIndex returns the pairs data of the position and its
+// index there.
+func (t *syzygyTable) boardIndex(b *Board) (*syzygyPairs, uint64, error) {
+	// Tables are of White with the material they are named
+	// by, and symmetric ones of White to move, so the colours
+	// and ranks are swapped if the board is the other way
+	counts := b.pieceCounts()
+	flip := materialSignature(&counts) != t.signature || t.symmetric && b.toMove == "b"
+	color, ranks, stm := 0, 0, 0
+	if flip {
+		color, ranks = 8, 56
+	}
+	if b.toMove == "b" {
+		stm = 1
+	}
+	if flip {
+		stm ^= 1
+	}
+
+	var squares, pieces [syzygyMaxPieces]int
+	size, lead, file := 0, 0, 0
+	pawn := -1
+	if t.hasPawns {
+		pawn = t.pairs[0][0].pieces[0] ^ color
+		for idx := 11; idx < 89; idx++ {
+			if syzygyPiece(b.board[idx]) == pawn {
+				if size == syzygyMaxPieces {
+					return nil, 0, ErrSyzygyDecode
+				}
+				squares[size] = syzygySquare(idx) ^ ranks
+				pieces[size] = pawn ^ color
+				size++
+			}
+		}
+		lead = size
+		for i := 1; i < lead; i++ {
+			if syzygyPawnMap[squares[i]] > syzygyPawnMap[squares[0]] {
+				squares[0], squares[i] = squares[i], squares[0]
+			}
+		}
+		file = squares[0] % 8
+		if file > 3 {
+			file = 7 - file
+		}
+	}
+	if t.dtz {
+		flags := t.pairs[0][file].flags
+		if flags&syzygySTM != stm && (!t.symmetric || t.hasPawns) {
+			return nil, 0, errSyzygySide
+		}
+	}
+	for idx := 11; idx < 89; idx++ {
+		piece := syzygyPiece(b.board[idx])
+		if piece == 0 || piece == pawn {
+			continue
+		}
+		if size == syzygyMaxPieces {
+			return nil, 0, ErrSyzygyDecode
+		}
+		squares[size] = syzygySquare(idx) ^ ranks
+		pieces[size] = piece ^ color
+		size++
+	}
+	if size != t.pieces {
+		return nil, 0, ErrSyzygyDecode
+	}
+	d := &t.pairs[stm%t.sides][file]
+	return d, t.index(d, squares[:size], pieces[:size], lead), nil
+}
+
+// index returns the index of the pieces on the squares, the
+// leading pawns first, in a table of the pairs data.
+func (t *syzygyTable) index(d *syzygyPairs, squares, pieces []int, lead int) uint64 {
+	size := len(squares)
+	// Put the pieces in the order of the table
+	for i := lead; i < size-1; i++ {
+		for j := i + 1; j < size; j++ {
+			if d.pieces[i] == pieces[j] {
+				pieces[i], pieces[j] = pieces[j], pieces[i]
+				squares[i], squares[j] = squares[j], squares[i]
+				break
+			}
+		}
+	}
+	// The leading piece goes on the a to d files
+	if squares[0]%8 > 3 {
+		for i := range squares {
+			squares[i] ^= 7
+		}
+	}
+
+	var idx uint64
+	if t.hasPawns {
+		idx = syzygyLeadPawnIdx[lead][squares[0]]
+		others := squares[1:lead]
+		sort.SliceStable(others, func(i, j int) bool {
+			return syzygyPawnMap[others[i]] < syzygyPawnMap[others[j]]
+		})
+		for i := 1; i < lead; i++ {
+			idx += syzygyBinomial[i][syzygyPawnMap[squares[i]]]
+		}
+	} else {
+		// and without pawns on the 1st to 4th ranks, and
+		// below the a1-h8 diagonal
+		if squares[0]/8 > 3 {
+			for i := range squares {
+				squares[i] ^= 56
+			}
+		}
+		for i := 0; i < d.groupLen[0]; i++ {
+			diagonal := syzygyDiagonal(squares[i])
+			if diagonal == 0 {
+				continue
+			}
+			if diagonal > 0 {
+				for j := i; j < size; j++ {
+					squares[j] = (squares[j]>>3 | squares[j]<<3) & 63
+				}
+			}
+			break
+		}
+		idx = t.leadIndex(squares)
+	}
+
+	// The other groups, each on the squares left
+	idx *= d.groupIdx[0]
+	start := d.groupLen[0]
+	pawns := t.bothPawns
+	for next := 1; d.groupLen[next] != 0; next++ {
+		group := squares[start : start+d.groupLen[next]]
+		sort.Ints(group)
+		var n uint64
+		for i, sq := range group {
+			for _, before := range squares[:start] {
+				if group[i] > before {
+					sq--
+				}
+			}
+			if pawns {
+				sq -= 8
+			}
+			n += syzygyBinomial[i+1][sq]
+		}
+		pawns = false
+		idx += n * d.groupIdx[next]
+		start += d.groupLen[next]
+	}
+	return idx
+}
+
+// leadIndex returns the index of the leading group without
+// pawns: the Kings, or three pieces if a side has a single
+// piece of a kind.
+func (t *syzygyTable) leadIndex(squares []int) uint64 {
+	if !t.unique {
+		return uint64(syzygyKK[syzygyA1D1D4[squares[0]]][squares[1]])
+	}
+	s0, s1, s2 := squares[0], squares[1], squares[2]
+	adjust1, adjust2 := 0, 0
+	if s1 > s0 {
+		adjust1++
+	}
+	if s2 > s0 {
+		adjust2++
+	}
+	if s2 > s1 {
+		adjust2++
+	}
+	var idx int
+	switch {
+	case syzygyDiagonal(s0) != 0:
+		idx = (syzygyA1D1D4[s0]*63+s1-adjust1)*62 + s2 - adjust2
+	case syzygyDiagonal(s1) != 0:
+		idx = (6*63+(s0/8)*28+syzygyB1H1H7[s1])*62 + s2 - adjust2
+	case syzygyDiagonal(s2) != 0:
+		idx = 6*63*62 + 4*28*62 + (s0/8)*7*28 + (s1/8-adjust1)*28 + syzygyB1H1H7[s2]
+	default:
+		idx = 6*63*62 + 4*28*62 + 4*7*28 + (s0/8)*7*6 + (s1/8-adjust1)*6 + s2/8 - adjust2
+	}
+	return uint64(idx)
+}
+
+// decompress returns the value at an index of the pairs data.
+func (t *syzygyTable) decompress(d *syzygyPairs, idx uint64) (int, error) {
+	if d.flags&syzygySingleValue != 0 {
+		return d.value, nil
+	}
+	// The sparse index gives the block and offset of the values
+	// in the middle of each span, so look from there
+	k := idx / d.span
+	if k >= uint64(d.sparseSize) {
+		return 0, ErrSyzygyDecode
+	}
+	entry := d.sparse + int(k)*6
+	block := t.uint(entry, 4, false)
+	offset := t.uint(entry+4, 2, false) + int(idx%d.span) - int(d.span/2)
+	blockLen := func(block int) int {
+		return t.uint(d.blockLen+2*block, 2, false)
+	}
+	for offset < 0 {
+		block--
+		if block < 0 {
+			return 0, ErrSyzygyDecode
+		}
+		offset += blockLen(block) + 1
+	}
+	for offset > blockLen(block) {
+		offset -= blockLen(block) + 1
+		block++
+		if block >= d.blockLenSize {
+			return 0, ErrSyzygyDecode
+		}
+	}
+	if block >= d.blocks {
+		return 0, ErrSyzygyDecode
+	}
+
+	// Read the codes of the block until the symbol with the value
+	ptr := d.start + block*int(d.blockSize)
+	buf := uint64(t.uint(ptr, 4, true))<<32 | uint64(t.uint(ptr+4, 4, true))
+	ptr += 8
+	bits := 64
+	var sym int
+	for {
+		l := 0
+		for buf < d.base64[l] {
+			l++
+		}
+		sym = int((buf-d.base64[l])>>uint(64-l-d.minSymLen)) + d.lowestSym[l]
+		if sym >= len(d.symLen) {
+			return 0, ErrSyzygyDecode
+		}
+		if offset < d.symLen[sym]+1 {
+			break
+		}
+		offset -= d.symLen[sym] + 1
+		l += d.minSymLen
+		buf <<= uint(l)
+		bits -= l
+		if bits <= 32 {
+			bits += 32
+			buf |= uint64(t.uint(ptr, 4, true)) << uint(64-bits)
+			ptr += 4
+		}
+	}
+
+	// and down its pairs to the value
+	for steps := 0; d.symLen[sym] != 0; steps++ {
+		if steps == len(d.symLen) {
+			return 0, ErrSyzygyDecode
+		}
+		left := d.left[sym]
+		if offset < d.symLen[left]+1 {
+			sym = left
+		} else {
+			offset -= d.symLen[left] + 1
+			sym = d.right[sym]
+		}
+	}
+	return d.left[sym], nil
+}
+
+// uint reads an unsigned number of n bytes, big or little endian,
+// with zeros past the end of the file.
+func (t *syzygyTable) uint(pos, n int, bigEndian bool) int {
+	var x int
+	for i := 0; i < n; i++ {
+		var c int
+		if pos+i < len(t.data) {
+			c = int(t.data[pos+i])
+		}
+		if bigEndian {
+			x = x<<8 | c
+		} else {
+			x |= c << uint(8*i)
+		}
+	}
+	return x
+}
+
+// dtzPlies turns a value of a DTZ table into plies.
+func (t *syzygyTable) dtzPlies(d *syzygyPairs, value int, wdl WDL) (int, error) {
+	if d.flags&syzygyMapped != 0 {
+		i := d.mapIdx[syzygyWDLMap[wdl+2]] + value
+		if d.flags&syzygyWide != 0 {
+			if t.mapStart+2*i+2 > len(t.data) {
+				return 0, ErrSyzygyDecode
+			}
+			value = t.uint(t.mapStart+2*i, 2, false)
+		} else {
+			if t.mapStart+i >= len(t.data) {
+				return 0, ErrSyzygyDecode
+			}
+			value = int(t.data[t.mapStart+i])
+		}
+	}
+	if wdl == Win && d.flags&syzygyWinPlies == 0 ||
+		wdl == Loss && d.flags&syzygyLossPlies == 0 ||
+		wdl == CursedWin || wdl == BlessedLoss {
+		value *= 2
+	}
+	return value + 1, nil
+}
@@ -0,0 +1,591 @@
+package ghess
+
+import (
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+)
+
+// There are no Syzygy files to test with, so the tests write
+// tables in the same format, of values which are known: the
+// KPK bitbase for KPvK.
+
+// syzygySymbol is a symbol of the compressed values, a value
+// or a pair of symbols.
+type syzygySymbol struct {
+	left, right int // right is 0xFFF for a value
+	values      int
+	count       int // in the compressed values
+	length      int // of its code
+}
+
+// littleEndian appends the n bytes of x.
+func littleEndian(data []byte, x, n int) []byte {
+	for i := 0; i < n; i++ {
+		data = append(data, byte(x>>uint(8*i)))
+	}
+	return data
+}
+
+// encodePairs compresses the values of a pairs data, returning
+// its sizes, sparse index, block lengths and blocks. Neighbours
+// which are common are paired, and the symbols Huffman coded
+// into blocks of 64 bytes.
+func encodePairs(flags int, values []int) (sizes, sparse, blockLens, blocks []byte) {
+	single := true
+	for _, v := range values {
+		single = single && v == values[0]
+	}
+	if single {
+		return []byte{byte(flags | syzygySingleValue), byte(values[0])}, nil, nil, nil
+	}
+
+	var symbols []syzygySymbol
+	leaves := make(map[int]int)
+	seq := make([]int, len(values))
+	for i, v := range values {
+		if _, ok := leaves[v]; !ok {
+			leaves[v] = len(symbols)
+			symbols = append(symbols, syzygySymbol{left: v, right: 0xFFF, values: 1})
+		}
+		seq[i] = leaves[v]
+	}
+	for round := 0; round < 8; round++ {
+		counts := make(map[[2]int]int)
+		var best [2]int
+		most := 0
+		for i := 0; i+1 < len(seq); i++ {
+			pair := [2]int{seq[i], seq[i+1]}
+			counts[pair]++
+			if counts[pair] > most {
+				best, most = pair, counts[pair]
+			}
+		}
+		if most < 16 {
+			break
+		}
+		symbols = append(symbols, syzygySymbol{left: best[0], right: best[1],
+			values: symbols[best[0]].values + symbols[best[1]].values})
+		paired := seq[:0]
+		for i := 0; i < len(seq); i++ {
+			if i+1 < len(seq) && seq[i] == best[0] && seq[i+1] == best[1] {
+				paired = append(paired, len(symbols)-1)
+				i++
+			} else {
+				paired = append(paired, seq[i])
+			}
+		}
+		seq = paired
+	}
+
+	// Every symbol gets a code, then the longest codes get the
+	// lowest symbols, and the lowest codes of their length
+	for _, s := range seq {
+		symbols[s].count++
+	}
+	type node struct {
+		weight  int
+		symbols []int
+	}
+	nodes := make([]node, len(symbols))
+	for s := range symbols {
+		nodes[s] = node{symbols[s].count + 1, []int{s}}
+	}
+	for len(nodes) > 1 {
+		sort.SliceStable(nodes, func(i, j int) bool {
+			return nodes[i].weight < nodes[j].weight
+		})
+		merged := node{nodes[0].weight + nodes[1].weight,
+			append(append([]int{}, nodes[0].symbols...), nodes[1].symbols...)}
+		for _, s := range merged.symbols {
+			symbols[s].length++
+		}
+		nodes = append(nodes[2:], merged)
+	}
+	order := make([]int, len(symbols))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return symbols[order[i]].length > symbols[order[j]].length
+	})
+	id := make([]int, len(symbols))
+	for i, s := range order {
+		id[s] = i
+	}
+	maxLen, minLen := symbols[order[0]].length, symbols[order[len(order)-1]].length
+	perLength := make([]int, maxLen+2)
+	for _, s := range symbols {
+		perLength[s.length]++
+	}
+	lowest, base := make([]int, maxLen-minLen+1), make([]int, maxLen-minLen+1)
+	for i := len(base) - 2; i >= 0; i-- {
+		lowest[i] = lowest[i+1] + perLength[minLen+i+1]
+		base[i] = (base[i+1] + perLength[minLen+i+1]) / 2
+	}
+
+	var lengths, starts []int
+	block := make([]byte, 64)
+	bit, n, total := 0, 0, 0
+	flush := func() {
+		blocks = append(blocks, block...)
+		lengths, starts = append(lengths, n), append(starts, total)
+		block = make([]byte, 64)
+		bit, n, total = 0, 0, total+n
+	}
+	for _, s := range seq {
+		l := symbols[s].length
+		code := base[l-minLen] + id[s] - lowest[l-minLen]
+		if bit+l > 8*len(block) {
+			flush()
+		}
+		for k := l - 1; k >= 0; k-- {
+			if code>>uint(k)&1 != 0 {
+				block[bit/8] |= 0x80 >> uint(bit%8)
+			}
+			bit++
+		}
+		n += symbols[s].values
+	}
+	flush()
+
+	// The sparse index has the block and offset of the value
+	// in the middle of each span
+	const span = 64
+	for k := 0; k*span < len(values); k++ {
+		v := k*span + span/2
+		b := 0
+		for b+1 < len(starts) && starts[b+1] <= v {
+			b++
+		}
+		sparse = littleEndian(sparse, b, 4)
+		sparse = littleEndian(sparse, v-starts[b], 2)
+	}
+	for _, l := range lengths {
+		blockLens = littleEndian(blockLens, l-1, 2)
+	}
+
+	sizes = []byte{byte(flags), 6, 6, 0}
+	sizes = littleEndian(sizes, len(lengths), 4)
+	sizes = append(sizes, byte(maxLen), byte(minLen))
+	for _, l := range lowest {
+		sizes = littleEndian(sizes, l, 2)
+	}
+	sizes = littleEndian(sizes, len(symbols), 2)
+	for _, s := range order {
+		left, right := symbols[s].left, symbols[s].right
+		if right != 0xFFF {
+			left, right = id[left], id[right]
+		}
+		sizes = append(sizes, byte(left), byte(left>>8&0xF|right<<4&0xF0), byte(right>>4))
+	}
+	if len(symbols)%2 == 1 {
+		sizes = append(sizes, 0)
+	}
+	return sizes, sparse, blockLens, blocks
+}
+
+// encodeSyzygy writes a table file of the values of each pairs
+// data, by side to move and file, with the DTZ maps.
+func encodeSyzygy(tbl *syzygyTable, values [2][4][]int, maps []byte) []byte {
+	data := append([]byte{}, syzygyWDLMagic...)
+	if tbl.dtz {
+		data = append([]byte{}, syzygyDTZMagic...)
+	}
+	flags := 0
+	if !tbl.symmetric {
+		flags |= 1
+	}
+	if tbl.hasPawns {
+		flags |= 2
+	}
+	data = append(data, byte(flags))
+	for f := 0; f < tbl.files; f++ {
+		data = append(data, 0) // the leading group first
+		if tbl.bothPawns {
+			data = append(data, 0x11)
+		}
+		for k := 0; k < tbl.pieces; k++ {
+			data = append(data, byte(tbl.pairs[0][f].pieces[k]|tbl.pairs[1][f].pieces[k]<<4))
+		}
+	}
+	if len(data)%2 == 1 {
+		data = append(data, 0)
+	}
+
+	var sparse, blockLens, blocks [][]byte
+	for f := 0; f < tbl.files; f++ {
+		for i := 0; i < tbl.sides; i++ {
+			sizes, s, l, b := encodePairs(tbl.pairs[i][f].flags, values[i][f])
+			data = append(data, sizes...)
+			sparse, blockLens, blocks = append(sparse, s), append(blockLens, l), append(blocks, b)
+		}
+	}
+	if tbl.dtz {
+		data = append(data, maps...)
+		if len(data)%2 == 1 {
+			data = append(data, 0)
+		}
+	}
+	for _, s := range sparse {
+		data = append(data, s...)
+	}
+	for _, l := range blockLens {
+		data = append(data, l...)
+	}
+	for _, b := range blocks {
+		for len(data)%64 != 0 {
+			data = append(data, 0)
+		}
+		data = append(data, b...)
+	}
+	return data
+}
+
+// newTestTable returns a table of the signature with the pieces
+// encoded in the order given, for every side and file.
+func newTestTable(t *testing.T, signature string, dtz bool, pieces ...int) *syzygyTable {
+	tbl := newSyzygyTable(signature, dtz)
+	order := [2]int{0, 0xF}
+	if tbl.bothPawns {
+		order[1] = 1
+	}
+	for f := 0; f < tbl.files; f++ {
+		for i := 0; i < 2; i++ {
+			d := &tbl.pairs[i][f]
+			copy(d.pieces[:], pieces)
+			if err := tbl.groups(d, order, f); err != nil {
+				t.Fatal(signature, err)
+			}
+		}
+	}
+	return tbl
+}
+
+// syzygyIdx turns a square into a Board coordinate.
+func syzygyIdx(sq int) int {
+	return (sq/8+1)*10 + 8 - sq%8
+}
+
+// syzygyFEN returns the FEN of the pieces by square.
+func syzygyFEN(pieces map[int]byte, toMove string) string {
+	var fen []byte
+	for rank := 7; rank >= 0; rank-- {
+		empty := byte(0)
+		for file := 0; file < 8; file++ {
+			val, ok := pieces[rank*8+file]
+			if !ok {
+				empty++
+				continue
+			}
+			if empty > 0 {
+				fen = append(fen, '0'+empty)
+				empty = 0
+			}
+			fen = append(fen, val)
+		}
+		if empty > 0 {
+			fen = append(fen, '0'+empty)
+		}
+		if rank > 0 {
+			fen = append(fen, '/')
+		}
+	}
+	return string(fen) + " " + toMove + " - - 0 60"
+}
+
+// writeKPK writes KPvK tables to dir. The WDL values are the
+// KPK bitbase's, and the DTZ of White's wins is mapped from the
+// pawn's rank, 10 moves on the 2nd to 15 on the 7th.
+func writeKPK(t *testing.T, dir string) {
+	wdl := newTestTable(t, "KPvK", false, 1, 6, 14)
+	dtz := newTestTable(t, "KPvK", true, 1, 6, 14)
+	var wdlValues, dtzValues [2][4][]int
+	var maps []byte
+	for f := 0; f < 4; f++ {
+		for stm := 0; stm < 2; stm++ {
+			wdlValues[stm][f] = make([]int, wdl.pairs[stm][f].size())
+			for i := range wdlValues[stm][f] {
+				wdlValues[stm][f][i] = int(Draw + 2)
+			}
+		}
+		dtz.pairs[0][f].flags = syzygyMapped
+		dtzValues[0][f] = make([]int, dtz.pairs[0][f].size())
+		maps = append(maps, 6, 10, 11, 12, 13, 14, 15, 0, 0, 0)
+
+		for pawn := 8 + f; pawn < 56; pawn += 8 {
+			for wk := 0; wk < 64; wk++ {
+				for bk := 0; bk < 64; bk++ {
+					if wk == pawn || bk == pawn || kpkDistance(wk, bk) <= 1 {
+						continue
+					}
+					for stm := 0; stm < 2; stm++ {
+						if stm == 0 && kpkPawnAttacks(pawn, bk) ||
+							!kpkProbe(true, stm == 0, syzygyIdx(wk), syzygyIdx(bk), syzygyIdx(pawn)) {
+							continue
+						}
+						d := &wdl.pairs[stm][f]
+						idx := wdl.index(d, []int{pawn, wk, bk}, []int{1, 6, 14}, 1)
+						wdlValues[stm][f][idx] = int(Win + 2)
+						if stm == 1 {
+							wdlValues[stm][f][idx] = int(Loss + 2)
+							continue
+						}
+						d = &dtz.pairs[0][f]
+						idx = dtz.index(d, []int{pawn, wk, bk}, []int{1, 6, 14}, 1)
+						dtzValues[0][f][idx] = pawn/8 - 1
+					}
+				}
+			}
+		}
+	}
+	for name, data := range map[string][]byte{
+		"KPvK.rtbw": encodeSyzygy(wdl, wdlValues, nil),
+		"KPvK.rtbz": encodeSyzygy(dtz, dtzValues, maps),
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestSyzygyDecompress(t *testing.T) {
+	tbl := newTestTable(t, "KQvK", false, 6, 14, 5)
+	var values [2][4][]int
+	for stm := 0; stm < 2; stm++ {
+		values[stm][0] = make([]int, tbl.pairs[stm][0].size())
+		for i := range values[stm][0] {
+			values[stm][0][i] = (i/5%3 + i/997%2*2 + stm) % 5
+		}
+	}
+	data := encodeSyzygy(tbl, values, nil)
+	decoded, err := readSyzygyTable("KQvK", data, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for stm := 0; stm < 2; stm++ {
+		d := &decoded.pairs[stm][0]
+		if d.blocks < 2 || len(d.symLen) <= 5 {
+			t.Error("Should have blocks and pairs", d.blocks, d.symLen)
+		}
+		for i, want := range values[stm][0] {
+			if value, err := decoded.decompress(d, uint64(i)); err != nil || value != want {
+				t.Fatal("Wrong value at", stm, i, value, want, err)
+			}
+		}
+	}
+
+	if _, err := readSyzygyTable("KQvK", data[:len(data)-64], false); err != ErrSyzygyDecode {
+		t.Error("A short file should be an error", err)
+	}
+	if _, err := readSyzygyTable("KRvK", data, false); err != ErrSyzygyDecode {
+		t.Error("Pieces of the wrong table should be an error", err)
+	}
+}
+
+func TestSyzygyLeadIndex(t *testing.T) {
+	seen := make(map[int]bool)
+	for k1 := 0; k1 < 64; k1++ {
+		if k1%8 > 3 || syzygyDiagonal(k1) > 0 || k1 > 27 {
+			continue
+		}
+		for k2 := 0; k2 < 64; k2++ {
+			if kpkDistance(k1, k2) <= 1 || syzygyDiagonal(k1) == 0 && syzygyDiagonal(k2) > 0 {
+				continue
+			}
+			code := syzygyKK[syzygyA1D1D4[k1]][k2]
+			if code < 0 || code >= 462 || seen[code] {
+				t.Fatal("Bad King code", k1, k2, code)
+			}
+			seen[code] = true
+		}
+	}
+	if len(seen) != 462 {
+		t.Error("There are 462 ways to place the Kings, got", len(seen))
+	}
+
+	seen = make(map[int]bool)
+	for sq := 8; sq < 56; sq++ {
+		seen[syzygyPawnMap[sq]] = true
+	}
+	if len(seen) != 48 || !seen[0] || !seen[47] {
+		t.Error("Pawn squares should be 0 - 47", seen)
+	}
+}
+
+func TestSyzygyIndex(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for _, test := range []struct {
+		signature string
+		pieces    []int  // in the order they are encoded
+		size      uint64 // of the first pairs data
+		samples   int
+	}{
+		{"KQvK", []int{6, 14, 5}, 31332, 200000},
+		{"KRRvK", []int{6, 14, 4, 4}, 462 * 62 * 61 / 2, 200000},
+		{"KPvK", []int{1, 6, 14}, 6 * 63 * 62, 200000},
+		{"KPPvK", []int{1, 1, 6, 14}, (47 + 45 + 43 + 41 + 39 + 37) * 62 * 61, 200000},
+		{"KPvKP", []int{1, 9, 6, 14}, 6 * 47 * 62 * 61, 200000},
+	} {
+		tbl := newTestTable(t, test.signature, false, test.pieces...)
+		if size := tbl.pairs[0][0].size(); size != test.size {
+			t.Error("Wrong size of", test.signature, size, test.size)
+		}
+
+		// Positions with the same index should be the same,
+		// but for the symmetries of the board
+		symmetries := 8
+		if tbl.hasPawns {
+			symmetries = 2
+		}
+		canonical := func(squares []int) string {
+			var key string
+			for s := 0; s < symmetries; s++ {
+				image := make([]byte, len(squares))
+				for i, sq := range squares {
+					if s&1 != 0 {
+						sq ^= 7
+					}
+					if s&2 != 0 {
+						sq ^= 56
+					}
+					if s&4 != 0 {
+						sq = (sq>>3 | sq<<3) & 63
+					}
+					image[i] = byte(sq)
+				}
+				for i := 1; i < len(image); i++ {
+					for j := i; j > 0 && test.pieces[j] == test.pieces[j-1] && image[j] < image[j-1]; j-- {
+						image[j], image[j-1] = image[j-1], image[j]
+					}
+				}
+				if s == 0 || string(image) < key {
+					key = string(image)
+				}
+			}
+			return key
+		}
+
+		seen := make(map[*syzygyPairs]map[uint64]string)
+		for n := 0; n < test.samples; n++ {
+			squares := make([]int, len(test.pieces))
+			pieces := make(map[int]byte)
+			for i, piece := range test.pieces {
+				for {
+					sq := rng.Intn(64)
+					if _, ok := pieces[sq]; ok || piece&7 == 1 && (sq < 8 || sq > 55) ||
+						piece == 14 && kpkDistance(sq, squares[0]) <= 1 && test.pieces[0] == 6 {
+						continue
+					}
+					squares[i] = sq
+					pieces[sq] = "?PNBRQK??pnbrqk"[piece]
+					break
+				}
+			}
+			b := NewBoard()
+			for sq := 0; sq < 64; sq++ {
+				b.board[syzygyIdx(sq)] = '.'
+				if val, ok := pieces[sq]; ok {
+					b.board[syzygyIdx(sq)] = val
+				}
+			}
+			d, idx, err := tbl.boardIndex(&b)
+			if err != nil {
+				t.Fatal(test.signature, err)
+			}
+			if idx >= d.size() {
+				t.Fatal("Index past the table", test.signature, squares, idx)
+			}
+			if seen[d] == nil {
+				seen[d] = make(map[uint64]string)
+			}
+			key := canonical(squares)
+			if other, ok := seen[d][idx]; ok && other != key {
+				t.Fatal("Different positions with the same index", test.signature, squares, []byte(other))
+			}
+			seen[d][idx] = key
+		}
+	}
+}
+
+func TestSyzygyProbe(t *testing.T) {
+	dir := t.TempDir()
+	writeKPK(t, dir)
+	tb, err := OpenSyzygy(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	game := NewBoard()
+	for fen, want := range map[string]WDL{
+		// Rule of the square
+		`k7/8/8/4P3/8/8/8/K7 w - - 0 60`:  Win,
+		`8/5k2/8/4P3/8/8/8/K7 w - - 0 60`: Draw,
+		// Opposition, who moves decides it
+		`8/4k3/8/4K3/4P3/8/8/8 b - - 0 60`: Loss,
+		`8/4k3/8/4K3/4P3/8/8/8 w - - 0 60`: Draw,
+		// Rook pawns
+		`k7/8/8/P7/8/8/8/7K w - - 0 60`:   Draw,
+		`8/8/1K6/P7/8/8/8/6k1 b - - 0 60`: Loss,
+		// Black with the pawn
+		`8/8/8/4p3/4k3/8/4K3/8 w - - 0 60`: Loss,
+		`8/8/8/4p3/4k3/8/4K3/8 b - - 0 60`: Draw,
+		// The pawn is taken
+		`8/8/8/8/8/8/3kP3/7K b - - 0 60`: Draw,
+	} {
+		if err := game.LoadFen(fen); err != nil {
+			t.Fatal(fen, err)
+		}
+		if wdl, err := tb.ProbeWDL(&game); err != nil || wdl != want {
+			t.Error("Wrong WDL", fen, wdl, err)
+		}
+	}
+
+	// Random positions, against the bitbase
+	rng := rand.New(rand.NewSource(1))
+	for n := 0; n < 2000; n++ {
+		wk, bk, pawn := rng.Intn(64), rng.Intn(64), 8+rng.Intn(48)
+		strongToMove := rng.Intn(2) == 0
+		if wk == pawn || bk == pawn || kpkDistance(wk, bk) <= 1 ||
+			strongToMove && kpkPawnAttacks(pawn, bk) {
+			continue
+		}
+		want := Draw
+		if kpkProbe(true, strongToMove, syzygyIdx(wk), syzygyIdx(bk), syzygyIdx(pawn)) {
+			want = Win
+			if !strongToMove {
+				want = Loss
+			}
+		}
+		fen := syzygyFEN(map[int]byte{wk: 'K', bk: 'k', pawn: 'P'}, map[bool]string{true: "w", false: "b"}[strongToMove])
+		if n%2 == 1 {
+			fen = syzygyFEN(map[int]byte{wk ^ 56: 'k', bk ^ 56: 'K', pawn ^ 56: 'p'}, map[bool]string{true: "b", false: "w"}[strongToMove])
+		}
+		if err := game.LoadFen(fen); err != nil {
+			t.Fatal(fen, err)
+		}
+		if wdl, err := tb.ProbeWDL(&game); err != nil || wdl != want {
+			t.Fatal("Wrong WDL", fen, wdl, want, err)
+		}
+	}
+
+	for fen, want := range map[string]int{
+		// The pawn is blocked, so the table has the distance,
+		// 13 moves with the pawn on the 5th
+		`4k3/8/4K3/4P3/8/8/8/8 w - - 0 60`: 27,
+		`8/8/8/8/4p3/4k3/8/4K3 b - - 0 60`: 27,
+		// Black's moves are a ply further, and not in the table
+		`4k3/8/4K3/4P3/8/8/8/8 b - - 0 60`: -28,
+		// The pawn moves next
+		`8/8/4P3/8/8/8/k7/4K3 b - - 0 60`: -2,
+		`8/5k2/8/4P3/8/8/8/K7 w - - 0 60`: 0,
+	} {
+		if err := game.LoadFen(fen); err != nil {
+			t.Fatal(fen, err)
+		}
+		if dtz, err := tb.ProbeDTZ(&game); err != nil || dtz != want {
+			t.Error("Wrong DTZ", fen, dtz, want, err)
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package ghess
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeSyzygy(t *testing.T, dir, name string, magic []byte) {
+	data := append(append([]byte{}, magic...), 0, 0, 0, 0)
+	if err := os.WriteFile(filepath.Join(dir, name), data, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestOpenSyzygy(t *testing.T) {
+	dir := t.TempDir()
+	writeSyzygy(t, dir, "KQvK.rtbw", syzygyWDLMagic)
+	writeSyzygy(t, dir, "KQvK.rtbz", syzygyDTZMagic)
+	writeSyzygy(t, dir, "KRPvKR.rtbw", syzygyWDLMagic)
+	writeSyzygy(t, dir, "README.txt", nil)
+	tb, err := OpenSyzygy(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tb.MaxPieces() != 5 {
+		t.Error("Largest table has 5 pieces, got", tb.MaxPieces())
+	}
+	if tables := tb.Tables(); len(tables) != 2 || tables[0] != "KQvK" {
+		t.Error("Wrong tables", tables)
+	}
+
+	game := NewBoard()
+	_ = game.LoadFen(`8/8/8/4k3/8/8/8/R3K3 w - - 0 60`)
+	if _, err := tb.ProbeWDL(&game); err != ErrNoTable {
+		t.Error("No KRvK table", err)
+	}
+	// Black's Queen is found in KQvK
+	_ = game.LoadFen(`8/8/8/4k3/8/8/8/q3K3 w - - 0 60`)
+	if _, err := tb.ProbeWDL(&game); err != ErrSyzygyDecode {
+		t.Error("KvKQ should be found", err)
+	}
+
+	writeSyzygy(t, dir, "KBvK.rtbw", syzygyDTZMagic)
+	if _, err := OpenSyzygy(dir); err == nil {
+		t.Error("Wrong magic should be an error")
+	}
+}
+
+func TestValidSignature(t *testing.T) {
+	for sig, want := range map[string]bool{
+		"KQvK": true, "KRPvKR": true, "KvK": true,
+		"QvK": false, "KQK": false, "KXvK": false, "KKvK": false,
+	} {
+		if validSignature(sig) != want {
+			t.Error("Wrong validity for", sig)
+		}
+	}
+}
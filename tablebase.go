@@ -0,0 +1,193 @@
+// Package ghess is a chess engine. This file concerns probing
+// endgame tablebases in the search, see Syzygy.
+package ghess
+
+import (
+	"fmt"
+)
+
+// WDL is the win, draw or loss of a tablebase position, for
+// the player to move. Cursed wins and blessed losses are won
+// or lost, but drawn by the fifty move rule.
+type WDL int
+
+// Tablebase results, from the player to move's point of view.
+const (
+	Loss WDL = iota - 2
+	BlessedLoss
+	Draw
+	CursedWin
+	Win
+)
+
+func (w WDL) String() string {
+	switch w {
+	case Loss:
+		return "Loss"
+	case BlessedLoss:
+		return "Blessed Loss"
+	case Draw:
+		return "Draw"
+	case CursedWin:
+		return "Cursed Win"
+	case Win:
+		return "Win"
+	}
+	return fmt.Sprintf("WDL(%d)", int(w))
+}
+
+// Tablebase probes endgame tables. Set SearchOptions.Tablebase
+// to use one in the search.
+type Tablebase interface {
+	// MaxPieces is the most pieces, Kings included, of any table.
+	MaxPieces() int
+	// ProbeWDL returns the result for the player to move.
+	ProbeWDL(b *Board) (WDL, error)
+	// ProbeDTZ returns the plies to the next capture or pawn
+	// move, the distance to zero, with the sign of the WDL.
+	ProbeDTZ(b *Board) (int, error)
+}
+
+// tbWin is the score of a tablebase win, below any mate
+// but above any evaluation.
+const tbWin = MateScore / 2
+
+// tbScore turns a WDL into a score from White's point of view.
+func tbScore(wdl WDL, whiteToMove bool, ply int) int {
+	var score int
+	switch wdl {
+	case Win:
+		score = tbWin - ply // the win closer to the root is better
+	case Loss:
+		score = -tbWin + ply
+	}
+	if !whiteToMove {
+		score = -score
+	}
+	return score
+}
+
+// zeroing returns whether a move is a capture, and whether
+// it's a pawn move, either of which zeroes the fifty move count.
+func (b *Board) zeroing(orig, dest int) (capture, pawn bool) {
+	val := b.board[orig]
+	pawn = val == 'P' || val == 'p'
+	return b.board[dest] != '.' || pawn && (orig-dest)%10 != 0, pawn
+}
+
+// dtzBeforeZeroing is the DTZ of a position whose best move
+// is a capture or pawn move.
+func dtzBeforeZeroing(wdl WDL) int {
+	switch wdl {
+	case Win:
+		return 1
+	case CursedWin:
+		return 101
+	case BlessedLoss:
+		return -101
+	case Loss:
+		return -1
+	}
+	return 0
+}
+
+// tablebaseOk returns true if the board has few enough
+// pieces for tb, and no castling, which tables don't know.
+func (b *Board) tablebaseOk(tb Tablebase) bool {
+	for _, c := range b.castle {
+		if c != '-' {
+			return false
+		}
+	}
+	var pieces int
+	for idx := 11; idx < 89; idx++ {
+		if val := b.board[idx]; val != '.' && val != ' ' {
+			pieces++
+		}
+	}
+	return pieces <= tb.MaxPieces()
+}
+
+// probeTablebase returns the tablebase score of s, and false
+// if there is no tablebase or the position isn't in it.
+func (s *State) probeTablebase() (int, bool) {
+	if s.opts == nil || s.opts.Tablebase == nil {
+		return 0, false
+	}
+	tb := s.opts.Tablebase
+	if s.board.Checkmate || !s.board.tablebaseOk(tb) {
+		return 0, false
+	}
+	wdl, err := tb.ProbeWDL(s.board)
+	if err != nil {
+		return 0, false
+	}
+	return tbScore(wdl, s.board.toMove == "w", s.ply), true
+}
+
+// tablebaseRoot picks the root move keeping the best result,
+// the quickest to zero when winning and the slowest when losing,
+// as the DTZ of the root after each move. Cursed wins and blessed
+// losses have 100 more, so they compare by DTZ too.
+// It returns false if any move can't be probed, so the search
+// goes on as normal.
+func (s State) tablebaseRoot() (State, bool) {
+	if s.opts == nil || s.opts.Tablebase == nil {
+		return s, false
+	}
+	tb := s.opts.Tablebase
+	if !s.board.tablebaseOk(tb) {
+		return s, false
+	}
+	states, err := GetPossibleStates(s)
+	if err != nil || len(states) == 0 {
+		return s, false
+	}
+	var best State
+	bestWDL, bestDTZ := Loss-1, 0
+	for _, state := range states {
+		// A mate is Win at 0, before any DTZ. The opponent is to
+		// move in the child, and its DTZ is a ply from the root's,
+		// but after a capture or pawn move it counts from zero
+		wdl, dtz := Win, 0
+		if !state.board.Checkmate {
+			childWDL, err := tb.ProbeWDL(state.board)
+			if err != nil {
+				return s, false
+			}
+			wdl = -childWDL
+			if capture, pawn := s.board.zeroing(state.move[0], state.move[1]); capture || pawn {
+				dtz = dtzBeforeZeroing(wdl)
+			} else {
+				childDTZ, err := tb.ProbeDTZ(state.board)
+				if err != nil {
+					return s, false
+				}
+				dtz = -childDTZ
+				if dtz > 0 {
+					dtz++
+				} else if dtz < 0 {
+					dtz--
+				}
+			}
+		}
+		better := wdl > bestWDL
+		if wdl == bestWDL {
+			switch {
+			case wdl > Draw:
+				better = dtz < bestDTZ
+			case wdl < Draw:
+				better = dtz < bestDTZ // the most negative holds out longest
+			default:
+				better = state.eval > best.eval == s.isMax
+			}
+		}
+		if better {
+			best, bestWDL, bestDTZ = state, wdl, dtz
+		}
+	}
+	if !best.board.Checkmate {
+		best.eval = tbScore(bestWDL, s.board.toMove == "w", 0)
+	}
+	return best, true
+}
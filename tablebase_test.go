@@ -0,0 +1,111 @@
+package ghess
+
+import (
+	"errors"
+	"testing"
+)
+
+// fakeTablebase wins only with the White Queen on d7,
+// and can be told to fail the DTZ probes.
+type fakeTablebase struct {
+	probes int
+	noDTZ  bool
+}
+
+func (tb *fakeTablebase) MaxPieces() int { return 3 }
+
+func (tb *fakeTablebase) ProbeWDL(b *Board) (WDL, error) {
+	tb.probes++
+	if b.board[75] == 'Q' && b.toMove == "b" {
+		return Loss, nil
+	}
+	return Draw, nil
+}
+
+func (tb *fakeTablebase) ProbeDTZ(b *Board) (int, error) {
+	if tb.noDTZ {
+		return 0, errors.New("No DTZ")
+	}
+	if wdl, _ := tb.ProbeWDL(b); wdl == Loss {
+		return -3, nil
+	}
+	return 0, nil
+}
+
+func TestTablebaseRoot(t *testing.T) {
+	game := NewBoard()
+	_ = game.LoadFen(`7k/8/8/8/8/8/8/3QK3 w - - 0 60`)
+	s := GetState(&game)
+	s.SetOptions(SearchOptions{NoDictionary: true, Tablebase: &fakeTablebase{}})
+	best, err := MiniMaxPruning(0, 3, s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if best.Init != [2]int{15, 75} || best.eval != tbWin {
+		t.Error("Should play the winning Qd7", best.Init, best.eval)
+	}
+}
+
+// rookTablebase has White winning every position, quickest
+// while Black keeps the rook on a2, which Rxa2 takes.
+type rookTablebase struct{}
+
+func (tb rookTablebase) MaxPieces() int { return 4 }
+
+func (tb rookTablebase) ProbeWDL(b *Board) (WDL, error) {
+	if b.toMove == "b" {
+		return Loss, nil
+	}
+	return Win, nil
+}
+
+func (tb rookTablebase) ProbeDTZ(b *Board) (int, error) {
+	dtz := 20
+	if b.board[28] == 'r' {
+		dtz = 1
+	}
+	if b.toMove == "b" {
+		dtz = -dtz
+	}
+	return dtz, nil
+}
+
+func TestTablebaseRootZeroing(t *testing.T) {
+	game := NewBoard()
+	_ = game.LoadFen(`7k/8/8/8/8/8/r7/R3K3 w - - 0 60`)
+	s := GetState(&game)
+	s.SetOptions(SearchOptions{NoDictionary: true, Tablebase: rookTablebase{}})
+	best, ok := s.tablebaseRoot()
+	// The quiet moves are 2 plies from zeroing, the capture 1
+	if !ok || best.Init != [2]int{18, 28} {
+		t.Error("Should take the rook, which zeroes", best.Init, ok)
+	}
+}
+
+func TestTablebaseCutoff(t *testing.T) {
+	game := NewBoard()
+	_ = game.LoadFen(`7k/8/8/8/8/8/8/3QK3 w - - 0 60`)
+	tb := &fakeTablebase{noDTZ: true}
+	s := GetState(&game)
+	s.SetOptions(SearchOptions{NoDictionary: true, Tablebase: tb})
+	best, err := MiniMaxPruning(0, 3, s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Without DTZ the root searches, and every other move is drawn
+	if best.Init != [2]int{15, 75} || tb.probes == 0 {
+		t.Error("Search should find the win by probing", best.Init, tb.probes)
+	}
+	if best.eval != tbWin-1 {
+		t.Error("Win should be scored a ply from the root", best.eval)
+	}
+}
+
+func TestTbScore(t *testing.T) {
+	if tbScore(Win, false, 2) != -tbWin+2 || tbScore(CursedWin, true, 0) != 0 {
+		t.Error("Wrong tablebase score")
+	}
+	if mateIn(tbWin) != 0 {
+		t.Error("Tablebase win isn't a mate")
+	}
+}
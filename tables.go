@@ -4,599 +4,25 @@ import "regexp"
 
 var (
 
-	/* ***************************************************
-	   Scoring Tables
-	   ***************************************************  */
-	matMap = map[byte]int{
-		'P': 100,
-		'N': 320,
-		'B': 330,
-		'R': 500,
-		'Q': 900,
-		'K': 20000,
-		'p': 100,
-		'n': 320,
-		'b': 330,
-		'r': 500,
-		'q': 900,
-		'k': 20000,
-	} // material map
-
-	blackPawnMap = map[int]int{
-		11: 0,
-		12: 0,
-		13: 0,
-		14: 0,
-		15: 0,
-		16: 0,
-		17: 0,
-		18: 0,
-
-		71: 5,
-		72: 10,
-		73: 10,
-		74: -20,
-		75: -20,
-		76: 10,
-		77: 10,
-		78: 5,
-
-		61: 5,
-		62: -5,
-		63: -10,
-		64: 0,
-		65: 0,
-		66: -10,
-		67: -5,
-		68: 5,
-
-		51: 0,
-		52: 0,
-		53: 0,
-		54: 20,
-		55: 20,
-		56: 0,
-		57: 0,
-		58: 0,
-
-		41: 5,
-		42: 5,
-		43: 10,
-		44: 25,
-		45: 25,
-		46: 10,
-		47: 5,
-		48: 5,
-
-		31: 10,
-		32: 10,
-		33: 20,
-		34: 30,
-		35: 30,
-		36: 20,
-		37: 10,
-		38: 10,
-
-		21: 50,
-		22: 50,
-		23: 50,
-		24: 50,
-		25: 50,
-		26: 50,
-		27: 50,
-		28: 50,
-
-		81: 0,
-		82: 0,
-		83: 0,
-		84: 0,
-		85: 0,
-		86: 0,
-		87: 0,
-		88: 0,
-	}
-	whitePawnMap = map[int]int{
-		11: 0,
-		12: 0,
-		13: 0,
-		14: 0,
-		15: 0,
-		16: 0,
-		17: 0,
-		18: 0,
-
-		21: 5,
-		22: 10,
-		23: 10,
-		24: -20,
-		25: -20,
-		26: 10,
-		27: 10,
-		28: 5,
-
-		31: 5,
-		32: -5,
-		33: -10,
-		34: 0,
-		35: 0,
-		36: -10,
-		37: -5,
-		38: 5,
-
-		41: 0,
-		42: 0,
-		43: 0,
-		44: 20,
-		45: 20,
-		46: 0,
-		47: 0,
-		48: 0,
-
-		51: 5,
-		52: 5,
-		53: 10,
-		54: 25,
-		55: 25,
-		56: 10,
-		57: 5,
-		58: 5,
-
-		61: 10,
-		62: 10,
-		63: 20,
-		64: 30,
-		65: 30,
-		66: 20,
-		67: 10,
-		68: 10,
-
-		71: 50,
-		72: 50,
-		73: 50,
-		74: 50,
-		75: 50,
-		76: 50,
-		77: 50,
-		78: 50,
-
-		81: 0,
-		82: 0,
-		83: 0,
-		84: 0,
-		85: 0,
-		86: 0,
-		87: 0,
-		88: 0,
-	}
-
-	blackBishopMap = map[int]int{
-		81: -20,
-		82: -10,
-		83: -10,
-		84: -10,
-		85: -10,
-		86: -10,
-		87: -10,
-		88: -20,
-
-		71: -10,
-		72: 5,
-		73: 0,
-		74: 0,
-		75: 0,
-		76: 0,
-		77: 5,
-		78: -10,
-
-		61: -10,
-		62: 10,
-		63: 10,
-		64: 10,
-		65: 10,
-		66: 10,
-		67: 10,
-		68: -10,
-
-		51: -10,
-		52: 0,
-		53: 10,
-		54: 10,
-		55: 10,
-		56: 10,
-		57: 0,
-		58: -10,
-
-		41: -10,
-		42: 5,
-		43: 5,
-		44: 10,
-		45: 10,
-		46: 5,
-		47: 5,
-		48: -10,
-
-		31: -10,
-		32: 0,
-		33: 5,
-		34: 10,
-		35: 10,
-		36: 5,
-		37: 0,
-		38: -10,
-
-		21: -20,
-		22: 0,
-		23: 0,
-		24: 0,
-		25: 0,
-		26: 0,
-		27: 0,
-		28: -20,
-
-		11: -20,
-		12: -10,
-		13: -10,
-		14: -10,
-		15: -10,
-		16: -10,
-		17: -10,
-		18: -20,
-	}
-	whiteBishopMap = map[int]int{
-		11: -20,
-		12: -10,
-		13: -10,
-		14: -10,
-		15: -10,
-		16: -10,
-		17: -10,
-		18: -20,
-		21: -10,
-		22: 5,
-		23: 0,
-		24: 0,
-		25: 0,
-		26: 0,
-		27: 5,
-		28: -10,
-		31: -10,
-		32: 10,
-		33: 10,
-		34: 10,
-		35: 10,
-		36: 10,
-		37: 10,
-		38: -10,
-		41: -10,
-		42: 0,
-		43: 10,
-		44: 10,
-		45: 10,
-		46: 10,
-		47: 0,
-		48: -10,
-		51: -10,
-		52: 5,
-		53: 5,
-		54: 10,
-		55: 10,
-		56: 5,
-		57: 5,
-		58: -10,
-		61: -10,
-		62: 0,
-		63: 5,
-		64: 10,
-		65: 10,
-		66: 5,
-		67: 0,
-		68: -10,
-		71: -20,
-		72: 0,
-		73: 0,
-		74: 0,
-		75: 0,
-		76: 0,
-		77: 0,
-		78: -20,
-		81: -20,
-		82: -10,
-		83: -10,
-		84: -10,
-		85: -10,
-		86: -10,
-		87: -10,
-		88: -20,
-	}
-	whiteKnightMap = map[int]int{
-		11: -50,
-		12: -40,
-		13: -30,
-		14: -30,
-		15: -30,
-		16: -30,
-		17: -40,
-		18: -50,
-		21: -40,
-		22: -20,
-		23: 0,
-		24: 5,
-		25: 5,
-		26: 0,
-		27: -20,
-		28: -40,
-		31: -30,
-		32: 5,
-		33: 10,
-		34: 15,
-		35: 15,
-		36: 10,
-		37: 5,
-		38: -30,
-		41: -30,
-		42: 0,
-		43: 15,
-		44: 20,
-		45: 20,
-		46: 15,
-		47: 0,
-		48: -30,
-		51: -30,
-		52: 5,
-		53: 15,
-		54: 20,
-		55: 20,
-		56: 15,
-		57: 5,
-		58: -30,
-		61: -30,
-		62: 0,
-		63: 10,
-		64: 15,
-		65: 15,
-		66: 10,
-		67: 0,
-		68: -30,
-		71: -40,
-		72: -20,
-		73: 0,
-		74: 0,
-		75: 0,
-		76: 0,
-		77: -20,
-		78: -40,
-		81: -50,
-		82: -40,
-		83: -30,
-		84: -30,
-		85: -30,
-		86: -30,
-		87: -40,
-		88: -50,
+	// Board offsets of the piece moves
+	knightMoves = [8]int{21, 19, 12, 8, -8, -12, -19, -21}
+	bishopMoves = [4]int{9, 11, -9, -11}
+	rookMoves   = [4]int{1, 10, -1, -10}
+	royalMoves  = [8]int{1, 10, -1, -10, 9, 11, -9, -11}
+
+	// Game phase weights of non-pawn material,
+	// all the pieces on the board is totalPhase.
+	phaseMap = map[byte]int{
+		'N': 1,
+		'B': 1,
+		'R': 2,
+		'Q': 4,
+		'n': 1,
+		'b': 1,
+		'r': 2,
+		'q': 4,
 	}
 
-	blackKnightMap = map[int]int{
-		11: -50,
-		12: -40,
-		13: -30,
-		14: -30,
-		15: -30,
-		16: -30,
-		17: -40,
-		18: -50,
-
-		21: -40,
-		22: -20,
-		23: 0,
-		24: 0,
-		25: 0,
-		26: 0,
-		27: -20,
-		28: -40,
-
-		31: -30,
-		32: 0,
-		33: 10,
-		34: 15,
-		35: 15,
-		36: 10,
-		37: 0,
-		38: -30,
-
-		41: -30,
-		42: 5,
-		43: 15,
-		44: 20,
-		45: 20,
-		46: 15,
-		47: 5,
-		48: -30,
-
-		51: -30,
-		52: 0,
-		53: 15,
-		54: 20,
-		55: 20,
-		56: 15,
-		57: 0,
-		58: -30,
-
-		61: -30,
-		62: 5,
-		63: 10,
-		64: 15,
-		65: 15,
-		66: 10,
-		67: 5,
-		68: -30,
-
-		71: -40,
-		72: -20,
-		73: 0,
-		74: 5,
-		75: 5,
-		76: 0,
-		77: -20,
-		78: -40,
-
-		81: -50,
-		82: -40,
-		83: -30,
-		84: -30,
-		85: -30,
-		86: -30,
-		87: -40,
-		88: -50,
-	}
-
-	whiteRookMap = map[int]int{
-		11: 0,
-		12: -10,
-		13: 0,
-		14: 5,
-		15: 5,
-		16: 0,
-		17: -10,
-		18: 0,
-
-		21: -5,
-		22: 0,
-		23: 0,
-		24: 0,
-		25: 0,
-		26: 0,
-		27: 0,
-		28: -5,
-
-		31: -5,
-		32: 0,
-		33: 0,
-		34: 0,
-		35: 0,
-		36: 0,
-		37: 0,
-		38: -5,
-
-		41: -5,
-		42: 0,
-		43: 0,
-		44: 0,
-		45: 0,
-		46: 0,
-		47: 0,
-		48: -5,
-
-		51: -5,
-		52: 0,
-		53: 0,
-		54: 0,
-		55: 0,
-		56: 0,
-		57: 0,
-		58: -5,
-
-		61: -5,
-		62: 0,
-		63: 0,
-		64: 0,
-		65: 0,
-		66: 0,
-		67: 0,
-		68: -5,
-
-		71: 5,
-		72: 10,
-		73: 10,
-		74: 10,
-		75: 10,
-		76: 10,
-		77: 10,
-		78: 5,
-
-		81: 0,
-		82: 0,
-		83: 0,
-		84: 0,
-		85: 0,
-		86: 0,
-		87: 0,
-		88: 0,
-	}
-	blackRookMap = map[int]int{
-		11: 0,
-		12: 0,
-		13: 0,
-		14: 0,
-		15: 0,
-		16: 0,
-		17: 0,
-		18: 0,
-
-		21: 5,
-		22: 10,
-		23: 10,
-		24: 10,
-		25: 10,
-		26: 10,
-		27: 10,
-		28: 5,
-
-		31: -5,
-		32: 0,
-		33: 0,
-		34: 0,
-		35: 0,
-		36: 0,
-		37: 0,
-		38: -5,
-
-		41: -5,
-		42: 0,
-		43: 0,
-		44: 0,
-		45: 0,
-		46: 0,
-		47: 0,
-		48: -5,
-
-		51: -5,
-		52: 0,
-		53: 0,
-		54: 0,
-		55: 0,
-		56: 0,
-		57: 0,
-		58: -5,
-
-		61: -5,
-		62: 0,
-		63: 0,
-		64: 0,
-		65: 0,
-		66: 0,
-		67: 0,
-		68: -5,
-
-		71: -5,
-		72: 0,
-		73: 0,
-		74: 0,
-		75: 0,
-		76: 0,
-		77: 0,
-		78: -5,
-
-		81: 0,
-		82: -10,
-		83: 0,
-		84: 5,
-		85: 5,
-		86: 0,
-		87: -10,
-		88: 0,
-	}
-	// TODO: Queen and King tables, King special for end game
-
 	/* ***************************************************
 	   Opening Dictionaries
 	   ***************************************************  */
@@ -659,7 +85,7 @@ var (
 	   ***************************************************  */
 	// Regex patterns for parsing
 	PgnPattern = /* const */ regexp.MustCompile(`([PNBRQK]?[a-h]?[1-8]?)x?([a-h][1-8])([\+\?\!]?)|O(-?O){1,2}`)
-	FenPattern = /* const */ regexp.MustCompile(`([PNBRQKpnbrqk\d]{1,8}/[PNBRQKpnbrqk\d]{1,8}/[PNBRQKpnbrqk\d]{1,8}/[PNBRQKpnbrqk\d]{1,8}/[PNBRQKpnbrqk\d]{1,8}/[PNBRQKpnbrqk\d]{1,8}/[PNBRQKpnbrqk\d]{1,8}/[PNBRQKpnbrqk\d]{1,8})\s(w|b)\s([KQkq-]{1,4})\s([a-h][36]|-)\s\d\s([1-9]?[1-9])`)
+	FenPattern = /* const */ regexp.MustCompile(`([PNBRQKpnbrqk\d]{1,8}/[PNBRQKpnbrqk\d]{1,8}/[PNBRQKpnbrqk\d]{1,8}/[PNBRQKpnbrqk\d]{1,8}/[PNBRQKpnbrqk\d]{1,8}/[PNBRQKpnbrqk\d]{1,8}/[PNBRQKpnbrqk\d]{1,8}/[PNBRQKpnbrqk\d]{1,8})\s(w|b)\s([KQkq-]{1,4})\s([a-h][36]|-)\s\d+\s([1-9]\d*)`)
 	// TODO: Enter the map values in NewBoard here
 	PgnRowMap = map[int][8]int{
 		1: {18, 17, 16, 15, 14, 13, 12, 11},
@@ -856,3 +282,225 @@ var (
 	// TODO: Black square Map
 
 )
+
+// totalPhase is the phase of the starting position, the
+// Middle Game, a phase of 0 is the End Game.
+const totalPhase = 24
+
+// DefaultParams returns the EvalParams the engine plays
+// with. The piece square tables are written as seen from
+// White, the 8th rank first, from the a to the h file. Black
+// uses the same tables flipped over. Pairs of weights are
+// {Middle Game, End Game}.
+func DefaultParams() *EvalParams {
+	ep := &EvalParams{
+		Material: map[string]int{
+			"P": 100,
+			"N": 320,
+			"B": 330,
+			"R": 500,
+			"Q": 900,
+			"K": 20000,
+		},
+		MidTables: map[string][64]int{
+			"P": {
+				0, 0, 0, 0, 0, 0, 0, 0,
+				50, 50, 50, 50, 50, 50, 50, 50,
+				10, 10, 20, 30, 30, 20, 10, 10,
+				5, 5, 10, 25, 25, 10, 5, 5,
+				0, 0, 0, 20, 20, 0, 0, 0,
+				5, -5, -10, 0, 0, -10, -5, 5,
+				5, 10, 10, -20, -20, 10, 10, 5,
+				0, 0, 0, 0, 0, 0, 0, 0,
+			},
+			"N": {
+				-50, -40, -30, -30, -30, -30, -40, -50,
+				-40, -20, 0, 0, 0, 0, -20, -40,
+				-30, 0, 10, 15, 15, 10, 0, -30,
+				-30, 5, 15, 20, 20, 15, 5, -30,
+				-30, 0, 15, 20, 20, 15, 0, -30,
+				-30, 5, 10, 15, 15, 10, 5, -30,
+				-40, -20, 0, 5, 5, 0, -20, -40,
+				-50, -40, -30, -30, -30, -30, -40, -50,
+			},
+			"B": {
+				-20, -10, -10, -10, -10, -10, -10, -20,
+				-20, 0, 0, 0, 0, 0, 0, -20,
+				-10, 0, 5, 10, 10, 5, 0, -10,
+				-10, 5, 5, 10, 10, 5, 5, -10,
+				-10, 0, 10, 10, 10, 10, 0, -10,
+				-10, 10, 10, 10, 10, 10, 10, -10,
+				-10, 5, 0, 0, 0, 0, 5, -10,
+				-20, -10, -10, -10, -10, -10, -10, -20,
+			},
+			"R": {
+				0, 0, 0, 0, 0, 0, 0, 0,
+				5, 10, 10, 10, 10, 10, 10, 5,
+				-5, 0, 0, 0, 0, 0, 0, -5,
+				-5, 0, 0, 0, 0, 0, 0, -5,
+				-5, 0, 0, 0, 0, 0, 0, -5,
+				-5, 0, 0, 0, 0, 0, 0, -5,
+				-5, 0, 0, 0, 0, 0, 0, -5,
+				0, -10, 0, 5, 5, 0, -10, 0,
+			},
+			"Q": {
+				-20, -10, -10, -5, -5, -10, -10, -20,
+				-10, 0, 0, 0, 0, 0, 0, -10,
+				-10, 0, 5, 5, 5, 5, 0, -10,
+				-5, 0, 5, 5, 5, 5, 0, -5,
+				0, 0, 5, 5, 5, 5, 0, -5,
+				-10, 5, 5, 5, 5, 5, 0, -10,
+				-10, 0, 5, 0, 0, 0, 0, -10,
+				-20, -10, -10, -5, -5, -10, -10, -20,
+			},
+			// The King hides in the Middle Game
+			"K": {
+				-30, -40, -40, -50, -50, -40, -40, -30,
+				-30, -40, -40, -50, -50, -40, -40, -30,
+				-30, -40, -40, -50, -50, -40, -40, -30,
+				-30, -40, -40, -50, -50, -40, -40, -30,
+				-20, -30, -30, -40, -40, -30, -30, -20,
+				-10, -20, -20, -20, -20, -20, -20, -10,
+				20, 20, 0, 0, 0, 0, 20, 20,
+				20, 30, 10, 0, 0, 10, 30, 20,
+			},
+		},
+		EndTables: map[string][64]int{
+			"P": {
+				0, 0, 0, 0, 0, 0, 0, 0,
+				80, 80, 80, 80, 80, 80, 80, 80,
+				50, 50, 50, 50, 50, 50, 50, 50,
+				30, 30, 30, 30, 30, 30, 30, 30,
+				15, 15, 15, 15, 15, 15, 15, 15,
+				5, 5, 5, 5, 5, 5, 5, 5,
+				0, 0, 0, 0, 0, 0, 0, 0,
+				0, 0, 0, 0, 0, 0, 0, 0,
+			},
+			"N": {
+				-40, -30, -20, -20, -20, -20, -30, -40,
+				-30, -10, 0, 0, 0, 0, -10, -30,
+				-20, 0, 10, 10, 10, 10, 0, -20,
+				-20, 0, 10, 15, 15, 10, 0, -20,
+				-20, 0, 10, 15, 15, 10, 0, -20,
+				-20, 0, 10, 10, 10, 10, 0, -20,
+				-30, -10, 0, 0, 0, 0, -10, -30,
+				-40, -30, -20, -20, -20, -20, -30, -40,
+			},
+			"B": {
+				-15, -10, -10, -10, -10, -10, -10, -15,
+				-10, 0, 0, 0, 0, 0, 0, -10,
+				-10, 0, 5, 5, 5, 5, 0, -10,
+				-10, 0, 5, 10, 10, 5, 0, -10,
+				-10, 0, 5, 10, 10, 5, 0, -10,
+				-10, 0, 5, 5, 5, 5, 0, -10,
+				-10, 0, 0, 0, 0, 0, 0, -10,
+				-15, -10, -10, -10, -10, -10, -10, -15,
+			},
+			"R": {
+				0, 0, 0, 0, 0, 0, 0, 0,
+				10, 10, 10, 10, 10, 10, 10, 10,
+				0, 0, 0, 0, 0, 0, 0, 0,
+				0, 0, 0, 0, 0, 0, 0, 0,
+				0, 0, 0, 0, 0, 0, 0, 0,
+				0, 0, 0, 0, 0, 0, 0, 0,
+				0, 0, 0, 0, 0, 0, 0, 0,
+				0, 0, 0, 0, 0, 0, 0, 0,
+			},
+			"Q": {
+				-20, -10, -10, -5, -5, -10, -10, -20,
+				-10, 0, 5, 5, 5, 5, 0, -10,
+				-10, 5, 10, 10, 10, 10, 5, -10,
+				-5, 5, 10, 15, 15, 10, 5, -5,
+				-5, 5, 10, 15, 15, 10, 5, -5,
+				-10, 5, 10, 10, 10, 10, 5, -10,
+				-10, 0, 5, 5, 5, 5, 0, -10,
+				-20, -10, -10, -5, -5, -10, -10, -20,
+			},
+			// The King comes out to the centre in the End Game
+			"K": {
+				-50, -40, -30, -20, -20, -30, -40, -50,
+				-30, -20, -10, 0, 0, -10, -20, -30,
+				-30, -10, 20, 30, 30, 20, -10, -30,
+				-30, -10, 30, 40, 40, 30, -10, -30,
+				-30, -10, 30, 40, 40, 30, -10, -30,
+				-30, -10, 20, 30, 30, 20, -10, -30,
+				-30, -30, 0, 0, 0, 0, -30, -30,
+				-50, -30, -30, -30, -30, -30, -30, -50,
+			},
+		},
+		Draw: -50000,
+
+		// Pawn Structure
+		DoubledPawn:   [2]int{-10, -20}, // for every extra pawn on a file
+		IsolatedPawn:  [2]int{-10, -15},
+		BackwardPawn:  [2]int{-8, -10},
+		ConnectedPawn: [2]int{5, 10}, // side by side or protected by a pawn
+		// Passed pawns by rank, counting from the pawn's own side
+		PassedPawn: [9][2]int{
+			{0, 0}, {0, 0}, {5, 10}, {5, 15}, {10, 25},
+			{20, 45}, {35, 75}, {60, 120}, {0, 0},
+		},
+		// Extra for a passed pawn with nothing in front of it
+		FreePassedPawn: [9][2]int{
+			{0, 0}, {0, 0}, {0, 5}, {0, 5}, {5, 10},
+			{5, 20}, {10, 35}, {15, 60}, {0, 0},
+		},
+
+		// Piece Activity
+		// For every square a piece can move to, more than its base
+		MobilityWeight: map[string][2]int{
+			"N": {4, 4},
+			"B": {5, 5},
+			"R": {2, 4},
+			"Q": {1, 2},
+		},
+		MobilityBase: map[string]int{
+			"N": 4,
+			"B": 6,
+			"R": 7,
+			"Q": 13,
+		},
+		RookOpenFile:     [2]int{20, 10}, // no pawns on the file
+		RookHalfOpenFile: [2]int{10, 5},  // no friendly pawns
+		RookSeventh:      [2]int{20, 30},
+		KnightOutpost:    [2]int{20, 15}, // protected, can't be chased by pawns
+		BishopOutpost:    [2]int{10, 5},
+		BishopPair:       [2]int{30, 50},
+
+		// King Safety, Middle Game only
+		// Friendly pawn one or two ranks in front of the King,
+		// on its own and the adjacent files
+		KingShelter:  [3]int{0, 15, 8},
+		KingNoPawn:   -15, // no friendly pawn on a file beside the King
+		KingOpenFile: -15, // and no enemy pawn either
+		// Enemy pawn one, two or three ranks in front of the King
+		PawnStorm: [4]int{0, -30, -20, -10},
+		// Attack units of a piece for every square it attacks
+		// around the King, counted with two or more attackers
+		KingAttackWeight: map[string]int{
+			"N": 2,
+			"B": 2,
+			"R": 3,
+			"Q": 5,
+		},
+		KingDangerMax: 500, // most a King can be penalised
+	}
+	if err := ep.Prepare(); err != nil {
+		panic(err)
+	}
+	return ep
+}
+
+// tableSquare turns an index of a table written as seen by
+// White, 8th rank first and from the a file, into a Board
+// coordinate.
+func tableSquare(i int) int {
+	rank, file := i/8, i%8
+	return (8-rank)*10 + (8 - file)
+}
+
+// mirrorSquare flips a Board coordinate to the other side
+// of the board.
+func mirrorSquare(idx int) int {
+	return (9-idx/10)*10 + idx%10
+}
@@ -0,0 +1,68 @@
+// Package ghess is a chess engine. This file concerns the
+// breakdown of the evaluation, for finding out why the
+// engine likes a position.
+package ghess
+
+import (
+	"fmt"
+)
+
+// EvalTrace is the score of Evaluate broken down by term.
+// Each term is [White, Black], from that side's point of view,
+// and already tapered by Phase. Total is the sum of White
+// minus Black for every term, plus the Draw penalty, the same
+// as Evaluate returns, unless an evaluator for the ending
+// named by Endgame replaced or scaled it.
+type EvalTrace struct {
+	Material   [2]int
+	PST        [2]int // piece square tables
+	Pawns      [2]int // pawn structure
+	Mobility   [2]int
+	Pieces     [2]int // rook files, outposts and bishop pair
+	KingSafety [2]int
+	Draw       int    // penalty for a drawn position
+	Phase      int    // totalPhase in the Middle Game, 0 in the End Game
+	Endgame    string // evaluator of the ending, if any
+	Total      int
+}
+
+// terms returns the scores of every term, named in traceNames.
+func (t EvalTrace) terms() [6][2]int {
+	return [6][2]int{
+		t.Material, t.PST, t.Pawns,
+		t.Mobility, t.Pieces, t.KingSafety,
+	}
+}
+
+var traceNames = [6]string{
+	"Material", "PST", "Pawns",
+	"Mobility", "Pieces", "King Safety",
+}
+
+// String prints the trace as a table, one term per line,
+// with the White, Black and White minus Black scores.
+func (t EvalTrace) String() string {
+	printTrace := fmt.Sprintf("%-12s|%8s|%8s|%8s|\n",
+		"Term", "White", "Black", "Total")
+	for i, term := range t.terms() {
+		printTrace += fmt.Sprintf("%-12s|%8d|%8d|%8d|\n",
+			traceNames[i], term[0], term[1], term[0]-term[1])
+	}
+	if t.Draw != 0 {
+		printTrace += fmt.Sprintf("%-12s|%8s|%8s|%8d|\n",
+			"Draw", "", "", t.Draw)
+	}
+	if t.Endgame != "" {
+		printTrace += fmt.Sprintf("Endgame: %s\n", t.Endgame)
+	}
+	printTrace += fmt.Sprintf("%-12s|%8s|%8s|%8d|\n",
+		"Total", "", "", t.Total)
+	printTrace += fmt.Sprintf("Phase: %d/%d\n", t.Phase, totalPhase)
+	return printTrace
+}
+
+// StringEvaluation prints the evaluation of the board by term,
+// see EvalTrace.
+func (b *Board) StringEvaluation() string {
+	return b.EvaluateTrace().String()
+}
@@ -0,0 +1,339 @@
+// Package ghess is a chess engine. This file concerns tuning
+// the evaluation weights from labelled positions, the Texel
+// method: the static evaluation, through a sigmoid, should
+// predict the result of the game the position came from.
+package ghess
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// TunePosition is a quiet position and the result of its
+// game, 1 for a White win, 0.5 for a draw and 0 for a loss.
+type TunePosition struct {
+	Board  Board
+	Result float64
+}
+
+// ReadTunePositions reads one position per line, a FEN or
+// EPD followed by the result as 1-0, 0-1 or 1/2-1/2, or as
+// 1.0, 0.5 or 0.0, in quotes or brackets or not. The move
+// counters are ignored, so they can be left out. Empty lines
+// and lines starting with # are skipped.
+func ReadTunePositions(r io.Reader) ([]TunePosition, error) {
+	positions := make([]TunePosition, 0)
+	scanner := bufio.NewScanner(r)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		fields := strings.Fields(text)
+		if len(fields) < 5 {
+			return nil, fmt.Errorf("Line %d: need a position and a result", line)
+		}
+		result := -1.0
+		for _, field := range fields[4:] {
+			switch strings.Trim(field, `"[];`) {
+			case "1-0", "1.0":
+				result = 1
+			case "0-1", "0.0":
+				result = 0
+			case "1/2-1/2", "0.5":
+				result = 0.5
+			}
+		}
+		if result < 0 {
+			return nil, fmt.Errorf("Line %d: no result", line)
+		}
+		b := NewBoard()
+		fen := strings.Join(fields[:4], " ") + " 0 1"
+		if err := b.LoadFen(fen); err != nil {
+			return nil, fmt.Errorf("Line %d: %v", line, err)
+		}
+		positions = append(positions, TunePosition{Board: b, Result: result})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return positions, nil
+}
+
+// LoadTunePositions reads a file of positions,
+// see ReadTunePositions.
+func LoadTunePositions(path string) ([]TunePosition, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ReadTunePositions(f)
+}
+
+// sigmoid turns an evaluation into the expected result
+// for White, scaled by k.
+func sigmoid(eval int, k float64) float64 {
+	return 1 / (1 + math.Pow(10, -k*float64(eval)/400))
+}
+
+// TuneError returns the mean squared error between the
+// results of the positions and their evaluation by ep.
+func TuneError(ep *EvalParams, positions []TunePosition, k float64) float64 {
+	if len(positions) == 0 {
+		return 0
+	}
+	workers := runtime.NumCPU()
+	sums := make([]float64, workers)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := w; i < len(positions); i += workers {
+				diff := positions[i].Result -
+					sigmoid(ep.Evaluate(&positions[i].Board), k)
+				sums[w] += diff * diff
+			}
+		}(w)
+	}
+	wg.Wait()
+	var sum float64
+	for _, s := range sums {
+		sum += s
+	}
+	return sum / float64(len(positions))
+}
+
+// FitK returns the sigmoid scaling which gives the least
+// error for ep, to be kept fixed while tuning.
+func FitK(ep *EvalParams, positions []TunePosition) float64 {
+	// Golden section search, the error has one minimum
+	lo, hi := 0.1, 3.0
+	ratio := (math.Sqrt(5) - 1) / 2
+	for hi-lo > 0.001 {
+		a := hi - ratio*(hi-lo)
+		c := lo + ratio*(hi-lo)
+		if TuneError(ep, positions, a) < TuneError(ep, positions, c) {
+			hi = c
+		} else {
+			lo = a
+		}
+	}
+	return (lo + hi) / 2
+}
+
+// TuneOptions are the settings of Tune.
+type TuneOptions struct {
+	K          float64  // sigmoid scaling, 0 to fit it with FitK
+	Step       int      // how far a weight is moved, 0 for 1
+	Iterations int      // passes over the weights, 0 until none improve
+	Weights    []string // prefixes of the weight names to tune, all if empty
+
+	// Log is called after each pass with the error.
+	Log func(iteration int, mse float64)
+}
+
+// tuneWeight is a single weight of EvalParams.
+type tuneWeight struct {
+	name string
+	get  func() int
+	set  func(int)
+}
+
+// tuneWeights returns every weight of ep, named after its
+// JSON field, then the piece and the index, such as
+// "midTables.N.27" or "passedPawn.5.1".
+func (ep *EvalParams) tuneWeights() []tuneWeight {
+	weights := make([]tuneWeight, 0)
+	add := func(name string, ptr *int) {
+		weights = append(weights, tuneWeight{name,
+			func() int { return *ptr },
+			func(val int) { *ptr = val }})
+	}
+	pairs := func(name string, pair *[2]int) {
+		add(name+".0", &pair[0])
+		add(name+".1", &pair[1])
+	}
+	// Map values can't be pointed to
+	addKey := func(name string, m map[string]int, key string) {
+		weights = append(weights, tuneWeight{name + "." + key,
+			func() int { return m[key] },
+			func(val int) { m[key] = val }})
+	}
+	addTables := func(name string, m map[string][64]int) {
+		for _, key := range []string{"P", "N", "B", "R", "Q", "K"} {
+			if _, ok := m[key]; !ok {
+				continue
+			}
+			for i := 0; i < 64; i++ {
+				key, i := key, i
+				weights = append(weights, tuneWeight{
+					fmt.Sprintf("%s.%s.%d", name, key, i),
+					func() int { return m[key][i] },
+					func(val int) {
+						table := m[key]
+						table[i] = val
+						m[key] = table
+					}})
+			}
+		}
+	}
+
+	for _, key := range sortedKeys(ep.Material) {
+		if key != "K" { // both sides always have one
+			addKey("material", ep.Material, key)
+		}
+	}
+	addTables("midTables", ep.MidTables)
+	addTables("endTables", ep.EndTables)
+	add("draw", &ep.Draw)
+	pairs("doubledPawn", &ep.DoubledPawn)
+	pairs("isolatedPawn", &ep.IsolatedPawn)
+	pairs("backwardPawn", &ep.BackwardPawn)
+	pairs("connectedPawn", &ep.ConnectedPawn)
+	for rank := 2; rank < 8; rank++ {
+		pairs(fmt.Sprintf("passedPawn.%d", rank), &ep.PassedPawn[rank])
+		pairs(fmt.Sprintf("freePassedPawn.%d", rank), &ep.FreePassedPawn[rank])
+	}
+	for _, key := range sortedKeys(ep.MobilityBase) {
+		for i := 0; i < 2; i++ {
+			key, i := key, i
+			weights = append(weights, tuneWeight{
+				fmt.Sprintf("mobilityWeight.%s.%d", key, i),
+				func() int { return ep.MobilityWeight[key][i] },
+				func(val int) {
+					pair := ep.MobilityWeight[key]
+					pair[i] = val
+					ep.MobilityWeight[key] = pair
+				}})
+		}
+	}
+	pairs("rookOpenFile", &ep.RookOpenFile)
+	pairs("rookHalfOpenFile", &ep.RookHalfOpenFile)
+	pairs("rookSeventh", &ep.RookSeventh)
+	pairs("knightOutpost", &ep.KnightOutpost)
+	pairs("bishopOutpost", &ep.BishopOutpost)
+	pairs("bishopPair", &ep.BishopPair)
+	// kingSafety finds the pawns by distance, not by their weights,
+	// so these can take any sign
+	for i := 1; i < len(ep.KingShelter); i++ {
+		add(fmt.Sprintf("kingShelter.%d", i), &ep.KingShelter[i])
+	}
+	add("kingNoPawn", &ep.KingNoPawn)
+	add("kingOpenFile", &ep.KingOpenFile)
+	for i := 1; i < len(ep.PawnStorm); i++ {
+		add(fmt.Sprintf("pawnStorm.%d", i), &ep.PawnStorm[i])
+	}
+	for _, key := range sortedKeys(ep.KingAttackWeight) {
+		addKey("kingAttackWeight", ep.KingAttackWeight, key)
+	}
+	add("kingDangerMax", &ep.KingDangerMax)
+	return weights
+}
+
+// sortedKeys returns the keys of m in order.
+func sortedKeys(m map[string]int) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// tuneSelected returns true if the weight is in the
+// prefixes, or there are none.
+func tuneSelected(name string, prefixes []string) bool {
+	if len(prefixes) == 0 {
+		return true
+	}
+	for _, prefix := range prefixes {
+		if name == prefix || strings.HasPrefix(name, prefix+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// Tune improves the weights of ep by local search: each weight
+// is moved up and then down by the step, and kept if the error
+// over the positions drops. It returns the tuned copy of ep and
+// its error, ep itself isn't changed.
+func Tune(ep *EvalParams, positions []TunePosition, opts TuneOptions) (*EvalParams, float64, error) {
+	if len(positions) == 0 {
+		return nil, 0, errors.New("No positions to tune with")
+	}
+	tuned, err := ep.clone()
+	if err != nil {
+		return nil, 0, err
+	}
+	k := opts.K
+	if k == 0 {
+		k = FitK(tuned, positions)
+	}
+	step := opts.Step
+	if step == 0 {
+		step = 1
+	}
+	weights := make([]tuneWeight, 0)
+	for _, w := range tuned.tuneWeights() {
+		if tuneSelected(w.name, opts.Weights) {
+			weights = append(weights, w)
+		}
+	}
+	if len(weights) == 0 {
+		return nil, 0, errors.New("No weights match " + strings.Join(opts.Weights, ", "))
+	}
+
+	best := TuneError(tuned, positions, k)
+	for iteration := 1; opts.Iterations == 0 || iteration <= opts.Iterations; iteration++ {
+		improved := false
+		for _, w := range weights {
+			val, kept := w.get(), false
+			for _, try := range [2]int{val + step, val - step} {
+				w.set(try)
+				if err := tuned.Prepare(); err != nil {
+					return nil, 0, err
+				}
+				if mse := TuneError(tuned, positions, k); mse < best {
+					best, kept = mse, true
+					break
+				}
+			}
+			if !kept {
+				w.set(val)
+				if err := tuned.Prepare(); err != nil {
+					return nil, 0, err
+				}
+			}
+			improved = improved || kept
+		}
+		if opts.Log != nil {
+			opts.Log(iteration, best)
+		}
+		if !improved {
+			break
+		}
+	}
+	return tuned, best, nil
+}
+
+// clone returns a deep copy of ep, prepared.
+func (ep *EvalParams) clone() (*EvalParams, error) {
+	var buf strings.Builder
+	if err := ep.WriteJSON(&buf); err != nil {
+		return nil, err
+	}
+	return ReadParams(strings.NewReader(buf.String()))
+}
@@ -0,0 +1,120 @@
+package ghess
+
+import (
+	"strings"
+	"testing"
+)
+
+const tuneData = `# Knight up wins, Knight down loses
+4k3/p7/8/8/8/8/P7/1N2K3 w - - c9 "1-0";
+1n2k3/p7/8/8/8/8/P7/4K3 b - - 0 1 [0.0]
+4k3/8/8/8/8/8/8/4K3 w - - 1/2-1/2
+`
+
+func TestReadTunePositions(t *testing.T) {
+	positions, err := ReadTunePositions(strings.NewReader(tuneData))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(positions) != 3 {
+		t.Fatal("Should read 3 positions, got", len(positions))
+	}
+	for i, want := range []float64{1, 0, 0.5} {
+		if positions[i].Result != want {
+			t.Error("Wrong result on line", i, positions[i].Result)
+		}
+	}
+	if positions[1].Board.board[87] != 'n' {
+		t.Error("Knight should be on b8")
+	}
+	_, err = ReadTunePositions(strings.NewReader(`4k3/8/8/8/8/8/8/4K3 w - - 0 1`))
+	if err == nil {
+		t.Error("Missing result should be an error")
+	}
+}
+
+func TestTuneWeights(t *testing.T) {
+	ep := DefaultParams()
+	names := make(map[string]bool)
+	for _, w := range ep.tuneWeights() {
+		if names[w.name] {
+			t.Error("Weight named twice", w.name)
+		}
+		names[w.name] = true
+	}
+	if names["material.K"] || !names["midTables.N.27"] || !names["passedPawn.5.1"] {
+		t.Error("Wrong weight names")
+	}
+	for _, w := range ep.tuneWeights() {
+		if w.name == "endTables.K.0" {
+			w.set(7)
+		}
+	}
+	if ep.EndTables["K"][0] != 7 {
+		t.Error("Weight not set", ep.EndTables["K"][0])
+	}
+}
+
+func TestTuneKingWeights(t *testing.T) {
+	// Stepping the shelter and storm weights across 0 changes
+	// the evaluation evenly, three pawns each step
+	game := NewBoard()
+	for fen, name := range map[string]string{
+		`r4rk1/ppp2ppp/8/8/8/8/PPP2PPP/R4RK1 w - - 0 20`: "kingShelter.1",
+		`r4rk1/ppp5/8/8/8/5ppp/PPP2PPP/R4RK1 w - - 0 20`: "pawnStorm.2",
+	} {
+		_ = game.LoadFen(fen)
+		ep := DefaultParams()
+		found := false
+		for _, w := range ep.tuneWeights() {
+			if w.name != name {
+				continue
+			}
+			found = true
+			w.set(-2)
+			last := game.kingSafety(ep, true)
+			for val := -1; val <= 2; val++ {
+				w.set(val)
+				safety := game.kingSafety(ep, true)
+				if safety-last != 3 {
+					t.Error("Uneven step of", name, "to", val, safety-last)
+				}
+				last = safety
+			}
+		}
+		if !found {
+			t.Error("No weight", name)
+		}
+	}
+}
+
+func TestTune(t *testing.T) {
+	positions, err := ReadTunePositions(strings.NewReader(tuneData))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ep := DefaultParams()
+	before := TuneError(ep, positions, 1)
+	tuned, after, err := Tune(ep, positions, TuneOptions{
+		K:          1,
+		Step:       10,
+		Iterations: 3,
+		Weights:    []string{"material.N"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if after >= before {
+		t.Error("Tuning should lower the error", before, after)
+	}
+	if tuned.Material["N"] != 350 {
+		t.Error("Knight should be worth more, got", tuned.Material["N"])
+	}
+	if ep.Material["N"] != 320 || tuned.Material["B"] != 330 {
+		t.Error("Only the tuned copy's Knight should change")
+	}
+	_, _, err = Tune(ep, positions, TuneOptions{Weights: []string{"none"}})
+	if err == nil {
+		t.Error("No matching weights should be an error")
+	}
+}
@@ -0,0 +1,74 @@
+// Package ghess is a chess engine. This file concerns moves in
+// the long algebraic notation of the UCI and CECP protocols,
+// such as e2e4, e1g1 for castling and e7e8q for promotion.
+package ghess
+
+import (
+	"errors"
+	"fmt"
+)
+
+// castleSquares maps where UCI puts the castling King
+// to the Rook the King takes in Board.Move.
+var castleSquares = map[[2]int]int{
+	{14, 12}: 11, // e1g1
+	{14, 16}: 18, // e1c1
+	{84, 82}: 81, // e8g8
+	{84, 86}: 88, // e8c8
+}
+
+// ParseUCI returns the Board coordinates of a move in long
+// algebraic notation. Board.Move only promotes to a Queen,
+// so other promotions are an error.
+func (b *Board) ParseUCI(move string) (int, int, error) {
+	if len(move) != 4 && len(move) != 5 {
+		return 0, 0, fmt.Errorf("Invalid move %q", move)
+	}
+	orig, ok := PgnToCoordMap[move[0:2]]
+	dest, ok2 := PgnToCoordMap[move[2:4]]
+	if !ok || !ok2 {
+		return 0, 0, fmt.Errorf("Invalid move %q", move)
+	}
+	if len(move) == 5 && move[4] != 'q' {
+		return 0, 0, errors.New("Only promotion to a Queen is supported")
+	}
+	if king := b.board[orig]; king == 'K' || king == 'k' {
+		if rook, ok := castleSquares[[2]int{orig, dest}]; ok {
+			dest = rook
+		}
+	}
+	return orig, dest, nil
+}
+
+// UCIMove returns a move of the board in long algebraic
+// notation, the King's square for castling.
+func (b *Board) UCIMove(orig, dest int) string {
+	piece := b.board[orig]
+	// Castling is the King taking its own Rook
+	if (piece == 'K' && b.board[dest] == 'R') || (piece == 'k' && b.board[dest] == 'r') {
+		for squares, rook := range castleSquares {
+			if squares[0] == orig && rook == dest {
+				dest = squares[1]
+			}
+		}
+	}
+	move := PieceMap[orig] + PieceMap[dest]
+	if (piece == 'P' && dest > 80) || (piece == 'p' && dest < 20) {
+		move += "q"
+	}
+	return move
+}
+
+// UCILine returns moves played from the board, such
+// as a principal variation, in long algebraic notation.
+func (b *Board) UCILine(moves [][2]int) []string {
+	line := make([]string, 0, len(moves))
+	c := *b
+	for _, move := range moves {
+		line = append(line, c.UCIMove(move[0], move[1]))
+		if err := c.Move(move[0], move[1]); err != nil {
+			break
+		}
+	}
+	return line
+}
@@ -0,0 +1,73 @@
+package ghess
+
+import (
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestParseUCI(t *testing.T) {
+	game := NewBoard()
+	if orig, dest, err := game.ParseUCI("e2e4"); err != nil || orig != 24 || dest != 44 {
+		t.Error("e2e4 should be 24 44", orig, dest, err)
+	}
+	if _, _, err := game.ParseUCI("e2e9"); err == nil {
+		t.Error("Off the board should be an error")
+	}
+	_ = game.LoadFen(`r3k2r/pppppppp/8/8/8/8/PPPPPPPP/R3K2R w KQkq - 0 10`)
+	orig, dest, err := game.ParseUCI("e1g1")
+	if err != nil || orig != 14 || dest != 11 {
+		t.Error("Castling should take the Rook", orig, dest, err)
+	}
+	if err := game.Move(orig, dest); err != nil {
+		t.Fatal(err)
+	}
+	if orig, dest, _ := game.ParseUCI("e8c8"); dest != 88 || game.UCIMove(orig, dest) != "e8c8" {
+		t.Error("Black castles Queen side", orig, dest)
+	}
+	_ = game.LoadFen(`8/4P3/8/8/8/8/k7/4K3 w - - 0 60`)
+	if _, _, err := game.ParseUCI("e7e8n"); err == nil {
+		t.Error("Knight promotion isn't supported")
+	}
+	if move := game.UCIMove(74, 84); move != "e7e8q" {
+		t.Error("Promotion should be to a Queen", move)
+	}
+}
+
+func TestUCILine(t *testing.T) {
+	game := NewBoard()
+	_ = game.LoadFen(`r3k2r/pppppppp/8/8/8/8/PPPPPPPP/R3K2R w KQkq - 0 10`)
+	line := game.UCILine([][2]int{{14, 11}, {84, 81}, {11, 12}})
+	if strings.Join(line, " ") != "e1g1 e8g8 h1g1" {
+		t.Error("Wrong line", line)
+	}
+}
+
+func TestSearchStop(t *testing.T) {
+	game := NewBoard()
+	stop := make(chan struct{})
+	close(stop)
+	s := GetState(&game)
+	s.SetOptions(SearchOptions{NoDictionary: true, Stop: stop})
+	if _, err := IterativeDeepening(3, s); err != ErrStopped {
+		t.Error("Stopped before depth 1 should be ErrStopped", err)
+	}
+
+	// Stop during depth 2, keeping depth 1
+	stop = make(chan struct{})
+	var once sync.Once
+	var depths []int
+	s.SetOptions(SearchOptions{NoDictionary: true, Stop: stop, Info: func(info SearchInfo) {
+		depths = append(depths, info.Depth)
+		if info.Depth == 2 {
+			once.Do(func() { close(stop) })
+		}
+	}})
+	best, err := IterativeDeepening(4, s)
+	if err != nil || best.Init == [2]int{} {
+		t.Error("Should return the depth 1 move", best.Init, err)
+	}
+	if depths[len(depths)-1] != 2 {
+		t.Error("Shouldn't search past depth 2", depths)
+	}
+}